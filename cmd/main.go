@@ -21,13 +21,32 @@ func main() {
 	}
 
 	log.Logger = zerolog.New(zerolog.MultiLevelWriter(zerolog.ConsoleWriter{Out: os.Stderr}, logFile)).With().Timestamp().Logger()
-	configPath := config.ParseArgs()
+	args, err := config.ParseArgs()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid arguments")
+	}
 
-	conf, err := config.LoadConfig()
+	conf, err := config.LoadConfig(args.Config, args.Flags)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed load config")
 	}
-	log.Info().Str("Path", configPath).Msg("Load config file")
+	log.Info().Str("Path", args.Config).Msg("Load config file")
+
+	// Validate every source and mapping before copying anything
+	if err := conf.Validate(); err != nil {
+		errs := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			errs = joined.Unwrap()
+		}
+		for _, e := range errs {
+			log.Error().Msg(e.Error())
+		}
+		log.Fatal().Msg("Invalid config")
+	}
+	if args.Command == config.CommandValidate {
+		log.Info().Msg("Config is valid")
+		return
+	}
 
 	collector, err := services.NewCollector(conf)
 	if err != nil {
@@ -35,5 +54,18 @@ func main() {
 	}
 	collector.CollectAll()
 
+	// Print planned merge on dry-run
+	if plan := collector.Plan(); plan != nil {
+		if format, _ := args.Flags.GetString("plan-format"); format == "json" {
+			b, err := plan.JSON()
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed marshal plan")
+			}
+			fmt.Println(string(b))
+		} else if err := plan.Table(os.Stdout); err != nil {
+			log.Fatal().Err(err).Msg("Failed print plan")
+		}
+	}
+
 	// time.Sleep(5 * time.Second)
 }
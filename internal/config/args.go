@@ -1,10 +1,81 @@
 package config
 
-import "flag"
+import (
+	"fmt"
+	"os"
+	"reflect"
 
-func ParseArgs() string {
-	conf := flag.String("config", "config.yaml", "config file path")
+	"github.com/spf13/pflag"
+)
 
-	flag.Parse()
-	return *conf
+// Commands
+const (
+	CommandCollect  = "collect"
+	CommandValidate = "validate"
+)
+
+// Args holds parsed command line arguments
+type Args struct {
+	// Command to run, defaults to collect
+	Command string
+	// Config file path
+	Config string
+	// Flags to override loaded config with
+	Flags *pflag.FlagSet
+}
+
+// ParseArgs parses command line as `[command] [flags]`.
+func ParseArgs() (Args, error) {
+	flags := pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
+	conf := flags.String("config", "config.yaml", "config file path (YAML, JSON or TOML)")
+	flags.String("dest", "", "destination dataset directory")
+	flags.String("output-format", "", "destination format: yolo, coco or voc")
+	flags.StringSlice("classes", nil, "destination class names")
+	flags.Int("workers", 0, "number of collecting workers")
+	flags.Bool("dry-run", false, "report the planned merge without writing any file")
+	flags.Bool("resume", false, "resume interrupted collection from destination manifest")
+	flags.Bool("append", false, "append onto dataset already written on destination")
+	flags.String("link-mode", "", "destination image link mode: copy, hardlink, symlink or reflink")
+	flags.String("plan-format", "table", "dry-run plan output: table or json")
+	addOverrideFlags(flags)
+
+	flags.Parse(os.Args[1:])
+
+	args := Args{Command: CommandCollect, Config: *conf, Flags: flags}
+	if flags.NArg() > 0 {
+		args.Command = flags.Arg(0)
+	}
+
+	switch args.Command {
+	case CommandCollect, CommandValidate:
+		return args, nil
+	}
+	return args, fmt.Errorf("unknown command %q", args.Command)
+}
+
+// addOverrideFlags adds a flag for every config key not declared yet, named by key with dashes
+func addOverrideFlags(flags *pflag.FlagSet) {
+	conf := reflect.ValueOf(&Config{}).Elem()
+	for _, key := range overrideKeys {
+		name := flagName(key)
+		if flags.Lookup(name) != nil {
+			continue
+		}
+
+		usage := fmt.Sprintf("override %s, also set by %s", key, overrideEnv(key))
+		switch configField(conf, key).Kind() {
+		case reflect.String:
+			flags.String(name, "", usage)
+		case reflect.Bool:
+			flags.Bool(name, false, usage)
+		case reflect.Int:
+			flags.Int(name, 0, usage)
+		case reflect.Int64:
+			flags.Int64(name, 0, usage)
+		case reflect.Float64:
+			flags.Float64(name, 0, usage)
+		case reflect.Slice:
+			flags.StringSlice(name, nil, usage)
+		}
+	}
 }
@@ -1,21 +1,50 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path"
+	"reflect"
+	"strings"
 
 	// "github.com/goccy/go-yaml"
 
 	"github.com/evilmagics/dataset_collector/internal/utils"
 	"github.com/goccy/go-json"
+	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
 
+// Source and output annotation formats
+const (
+	FormatYOLO = "yolo"
+	FormatCOCO = "coco"
+	FormatVOC  = "voc"
+)
+
+// YOLO label types: one box per line, class with polygon points, or class with 4 oriented box corners
+const (
+	LabelTypeDetect  = "detect"
+	LabelTypeSegment = "segment"
+	LabelTypeOBB     = "obb"
+)
+
+// Source is a dataset to collect, either a directory or a zip, tar or tar.gz archive,
+// local or downloaded from url
 type Source struct {
 	Src           string              `yaml:"src" json:"src"`
+	Format        string              `yaml:"format" json:"format"`
 	ClassSync     utils.ClassNameSync `yaml:"class_name_sync" json:"class_name_sync"`
 	DatasetConfig *Dataset            `yaml:"-" json:"data_config"`
+	// LabelType of source labels, detected from its label lines when empty
+	LabelType string `yaml:"label_type" json:"label_type"`
+	// URL of source archive downloaded into cache, instead of src
+	URL string `yaml:"url" json:"url"`
+	// SHA256 checksum of source archive downloaded from url
+	SHA256 string `yaml:"sha256" json:"sha256"`
 }
 
 func (s *Source) LoadDatasetConfig(fs afero.Fs) (err error) {
@@ -26,11 +55,194 @@ func (s *Source) LoadDatasetConfig(fs afero.Fs) (err error) {
 	return err
 }
 
+// Dedup keep policies
+const (
+	DedupKeepFirst   = "first"
+	DedupKeepLargest = "largest"
+)
+
+// Dedup configures detection of duplicated images across every source
+type Dedup struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Perceptual detects near duplicates by perceptual hash, besides identical content
+	Perceptual bool `yaml:"perceptual" json:"perceptual"`
+	// Threshold is the max hamming distance of perceptual hashes considered near duplicates, up to 64.
+	// Defaults to 5 when 0, -1 matches identical perceptual hashes only.
+	Threshold int `yaml:"threshold" json:"threshold"`
+	// Policy selects the kept copy: first (by source order) or largest (by resolution)
+	Policy string `yaml:"policy" json:"policy"`
+}
+
+// Leakage actions
+const (
+	LeakageReport = "report"
+	LeakageMove   = "move"
+)
+
+// Leakage configures detection of images collected into more than one category
+type Leakage struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Perceptual detects near duplicates by perceptual hash, besides identical content
+	Perceptual bool `yaml:"perceptual" json:"perceptual"`
+	// Threshold is the max hamming distance of perceptual hashes considered near duplicates, up to 64.
+	// Defaults to 5 when 0, -1 matches identical perceptual hashes only.
+	Threshold int `yaml:"threshold" json:"threshold"`
+	// Action on leaked images: report only, or move every copy into a single category
+	Action string `yaml:"action" json:"action"`
+}
+
+// Split configures re-assigning categories of every collected item by ratios,
+// instead of the source folder categories
+type Split struct {
+	Enabled bool    `yaml:"enabled" json:"enabled"`
+	Train   float64 `yaml:"train" json:"train"`
+	Valid   float64 `yaml:"valid" json:"valid"`
+	Test    float64 `yaml:"test" json:"test"`
+	Seed    int64   `yaml:"seed" json:"seed"`
+	// Stratify spreads objects of every class over categories by the same ratios
+	Stratify bool `yaml:"stratify" json:"stratify"`
+}
+
+// Ratios returns category ratios normalized to sum of 1
+func (s Split) Ratios() map[utils.Category]float64 {
+	sum := s.Train + s.Valid + s.Test
+	if sum <= 0 {
+		return nil
+	}
+	return map[utils.Category]float64{
+		utils.CategoryTrain: s.Train / sum,
+		utils.CategoryValid: s.Valid / sum,
+		utils.CategoryTest:  s.Test / sum,
+	}
+}
+
+// Group configures grouping of items derived from the same original image,
+// keeping every member of a group on the same category when splitting
+type Group struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Pattern matches source image filename, its first submatch being the group key.
+	// Defaults to Roboflow augmented variants, e.g. "IMG_0276_jpg.rf.<hash>.jpg".
+	Pattern string `yaml:"pattern" json:"pattern"`
+}
+
+// ClassBalance limits items collected for a destination class, zero means no limit
+type ClassBalance struct {
+	// MaxObjects caps objects of class, images which would exceed it are skipped
+	MaxObjects int `yaml:"max_objects" json:"max_objects"`
+	// MaxImages caps images holding class, images which would exceed it are skipped
+	MaxImages int `yaml:"max_images" json:"max_images"`
+	// MinImages oversamples images holding class, collecting copies with new ids until reached
+	MinImages int `yaml:"min_images" json:"min_images"`
+}
+
+// Balance configures class distribution of collected items, keyed by destination class
+type Balance struct {
+	Enabled bool                    `yaml:"enabled" json:"enabled"`
+	Classes map[string]ClassBalance `yaml:"classes" json:"classes"`
+}
+
+// Label validation modes
+const (
+	LabelReject     = "reject"
+	LabelClip       = "clip"
+	LabelDropObject = "drop-object"
+)
+
+// Labels configures validation of YOLO label lines
+type Labels struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Mode on invalid line: reject the whole item, clip box into image bounds
+	// dropping objects which can't be repaired, or drop invalid objects. Defaults to reject.
+	Mode string `yaml:"mode" json:"mode"`
+}
+
+// Image output formats
+const (
+	ImageJPEG = "jpeg"
+	ImagePNG  = "png"
+	ImageWebP = "webp"
+)
+
+// Size is an image size in pixels
+type Size struct {
+	Width  int `yaml:"width" json:"width"`
+	Height int `yaml:"height" json:"height"`
+}
+
+// Image link modes of destination images to their source
+const (
+	LinkCopy     = "copy"
+	LinkHardlink = "hardlink"
+	LinkSymlink  = "symlink"
+	LinkReflink  = "reflink"
+)
+
+// EXIF orientation handling, by orientation source labels were annotated on
+const (
+	OrientStored    = "stored"
+	OrientDisplayed = "displayed"
+)
+
+// Transform configures re-encoding of images written on destination, adjusting labels to match
+type Transform struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Format converts every image into jpeg, png or webp, keeping source format when empty
+	Format string `yaml:"format" json:"format"`
+	// MaxSide downsizes images whose longer side exceeds it, keeping aspect ratio
+	MaxSide int `yaml:"max_side" json:"max_side"`
+	// Letterbox fits images into a fixed size, padding borders to keep aspect ratio
+	Letterbox Size `yaml:"letterbox" json:"letterbox"`
+	// Quality of JPEG encoding from 1 to 100, defaults to 90
+	Quality int `yaml:"quality" json:"quality"`
+	// Orient rotates JPEG images by their EXIF orientation, stripping the tag. Labels annotated on
+	// stored pixels are rotated with image, labels annotated on displayed orientation are kept.
+	Orient string `yaml:"orient" json:"orient"`
+}
+
+// Archive limits reading archive sources, zero uses defaults
+type Archive struct {
+	// MaxSize of uncompressed archive content in bytes, defaults to 1 GiB.
+	// Tar.gz content is extracted into a temporary file until collected, taking up to MaxSize of disk.
+	MaxSize int64 `yaml:"max_size" json:"max_size"`
+	// MaxFiles of archive entries, defaults to 1000000
+	MaxFiles int `yaml:"max_files" json:"max_files"`
+}
+
+// Download configures downloading of source archives
+type Download struct {
+	// Cache directory of downloaded archives, defaults to user cache directory
+	Cache string `yaml:"cache" json:"cache"`
+	// Retries of failed download, with exponential backoff. Defaults to 3 when 0, -1 disables retries.
+	Retries int `yaml:"retries" json:"retries"`
+}
+
 type Config struct {
-	Dest    string   `yaml:"dest" json:"dest"`
-	Classes []string `yaml:"classes" json:"classes"`
-	Sources []Source `yaml:"sources" json:"sources"`
-	Workers int      `yaml:"workers" json:"workers"`
+	Dest         string    `yaml:"dest" json:"dest"`
+	OutputFormat string    `yaml:"output_format" json:"output_format"`
+	Classes      []string  `yaml:"classes" json:"classes"`
+	Sources      []Source  `yaml:"sources" json:"sources"`
+	Workers      int       `yaml:"workers" json:"workers"`
+	DryRun       bool      `yaml:"dry_run" json:"dry_run"`
+	Resume       bool      `yaml:"resume" json:"resume"`
+	Append       bool      `yaml:"append" json:"append"`
+	Dedup        Dedup     `yaml:"dedup" json:"dedup"`
+	Leakage      Leakage   `yaml:"leakage" json:"leakage"`
+	Split        Split     `yaml:"split" json:"split"`
+	Group        Group     `yaml:"group" json:"group"`
+	Balance      Balance   `yaml:"balance" json:"balance"`
+	Labels       Labels    `yaml:"labels" json:"labels"`
+	Transform    Transform `yaml:"transform" json:"transform"`
+	Archive      Archive   `yaml:"archive" json:"archive"`
+	Download     Download  `yaml:"download" json:"download"`
+	// LabelType of destination labels. Polygons and oriented boxes are converted to boxes on detect,
+	// otherwise source labels are kept as is. COCO and VOC outputs always hold boxes.
+	LabelType string `yaml:"label_type" json:"label_type"`
+	// LinkMode of destination images not transformed: copy, hardlink, symlink,
+	// or reflink falling back to copy when unsupported. Defaults to copy.
+	LinkMode string `yaml:"link_mode" json:"link_mode"`
+	// NamesForm of destination data.yaml names: list, or map of class id to name.
+	// Defaults to the form of existing destination on append, otherwise list.
+	NamesForm string `yaml:"names_form" json:"names_form"`
 }
 
 func (c Config) String() string {
@@ -41,16 +253,142 @@ func (c Config) String() string {
 	return string(j)
 }
 
-func LoadConfig() (*Config, error) {
-	f, err := os.ReadFile("./config.yaml")
+// LoadConfig loads config file decoded by its extension (YAML, JSON or TOML),
+// then applies overrides from DSC_* environment variables and changed flags.
+// Flags are bound by key name with dashes, e.g. output_format as --output-format,
+// nested keys joined by dot, e.g. --transform.max-side or DSC_TRANSFORM_MAX_SIDE.
+func LoadConfig(src string, flags ...*pflag.FlagSet) (*Config, error) {
+	f, err := os.ReadFile(src)
 	if err != nil {
 		return nil, err
 	}
 
 	conf := new(Config)
-	if err = yaml.Unmarshal(f, &conf); err != nil {
+	switch strings.ToLower(path.Ext(src)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(f, conf)
+	case ".json":
+		err = json.Unmarshal(f, conf)
+	case ".toml":
+		// Decode through JSON to reuse json tags of config
+		var raw map[string]interface{}
+		if err = toml.Unmarshal(f, &raw); err != nil {
+			return nil, err
+		}
+		var b []byte
+		if b, err = json.Marshal(raw); err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(b, conf)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q", path.Ext(src))
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	return conf, err
+	v := viper.New()
+	v.SetEnvPrefix("DSC")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	for _, fs := range flags {
+		for _, key := range overrideKeys {
+			if flag := fs.Lookup(flagName(key)); flag != nil {
+				if err := v.BindPFlag(key, flag); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	conf.override(v)
+
+	return conf, nil
+}
+
+// overrideKeys lists every config key overridable from environment variables and flags,
+// nested keys joined by dot, e.g. dedup.threshold. Sources and maps are only set from config file.
+var overrideKeys = configKeys(reflect.TypeOf(Config{}), "")
+
+// configKeys returns key by yaml tag of every scalar and string list field of struct t
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key, ft := prefix+name, t.Field(i).Type
+		switch ft.Kind() {
+		case reflect.Struct:
+			keys = append(keys, configKeys(ft, key+".")...)
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+			keys = append(keys, key)
+		case reflect.Slice:
+			if ft.Elem().Kind() == reflect.String {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// configField returns field of struct v found by its key
+func configField(v reflect.Value, key string) reflect.Value {
+	name, rest, nested := strings.Cut(key, ".")
+	for i := 0; i < v.NumField(); i++ {
+		if tag, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ","); tag == name {
+			if nested {
+				return configField(v.Field(i), rest)
+			}
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// flagName returns flag overriding config key, with dashes instead of underscores
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// overrideEnv returns environment variable overriding config key, e.g. DSC_DEDUP_THRESHOLD
+func overrideEnv(key string) string {
+	return "DSC_" + strings.ToUpper(strings.NewReplacer(".", "_").Replace(key))
+}
+
+func (c *Config) override(v *viper.Viper) {
+	conf := reflect.ValueOf(c).Elem()
+	for _, key := range overrideKeys {
+		if !v.IsSet(key) {
+			continue
+		}
+
+		f := configField(conf, key)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(v.GetString(key))
+		case reflect.Bool:
+			f.SetBool(v.GetBool(key))
+		case reflect.Int, reflect.Int64:
+			f.SetInt(v.GetInt64(key))
+		case reflect.Float64:
+			f.SetFloat(v.GetFloat64(key))
+		case reflect.Slice:
+			f.Set(reflect.ValueOf(splitList(v.GetStringSlice(key))).Convert(f.Type()))
+		}
+	}
+}
+
+// splitList splits comma separated values, as given from environment variables
+func splitList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
 }
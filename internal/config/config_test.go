@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+)
+
+const tomlSample = `
+dest = "./out"
+classes = ["car", "bus"]
+workers = 10
+
+[[sources]]
+src = "./src"
+format = "coco"
+
+[sources.class_name_sync]
+car = ["car", "van"]
+`
+
+func TestLoadConfigOverrides(t *testing.T) {
+	src := path.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(src, []byte(tomlSample), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DSC_DEST", "./env")
+	t.Setenv("DSC_CLASSES", "car,truck")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("workers", 0, "")
+	flags.String("dest", "", "")
+	flags.Parse([]string{"--workers", "20"})
+
+	conf, err := LoadConfig(src, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Dest != "./env" || conf.Workers != 20 {
+		t.Errorf("got dest %q workers %d, want ./env and 20", conf.Dest, conf.Workers)
+	}
+	if len(conf.Classes) != 2 || conf.Classes[1] != "truck" {
+		t.Errorf("got classes %v", conf.Classes)
+	}
+	if len(conf.Sources) != 1 || conf.Sources[0].Format != FormatCOCO {
+		t.Fatalf("got sources %+v", conf.Sources)
+	}
+	if cross := conf.Sources[0].ClassSync.GetCrossName("van"); cross == nil || *cross != "car" {
+		t.Errorf("van not synced to car")
+	}
+}
+
+func TestLoadConfigNestedOverrides(t *testing.T) {
+	src := path.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(src, []byte(tomlSample+"\n[dedup]\nthreshold = 3\npolicy = \"first\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DSC_DEDUP_THRESHOLD", "8")
+	t.Setenv("DSC_SPLIT_TRAIN", "0.7")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	addOverrideFlags(flags)
+	flags.Parse([]string{"--transform.letterbox.width", "640", "--split.enabled", "--dedup.policy", "largest"})
+
+	conf, err := LoadConfig(src, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Dedup.Threshold != 8 || conf.Dedup.Policy != DedupKeepLargest {
+		t.Errorf("got dedup %+v, want threshold 8 and policy largest", conf.Dedup)
+	}
+	if !conf.Split.Enabled || conf.Split.Train != 0.7 {
+		t.Errorf("got split %+v, want enabled with train 0.7", conf.Split)
+	}
+	if conf.Transform.Letterbox.Width != 640 {
+		t.Errorf("got letterbox width %d, want 640", conf.Transform.Letterbox.Width)
+	}
+	if conf.Workers != 10 {
+		t.Errorf("got workers %d, want 10 kept from file", conf.Workers)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	fs.MkdirAll("src", 0755)
+
+	conf := Config{
+		Dest:    "dst",
+		Classes: []string{"car", "car"},
+		Sources: []Source{{Src: "src"}, {Src: "missing", Format: "csv"}},
+	}
+	conf.Sources[0].ClassSync.UnmarshalJSON([]byte(`{"bus": ["bus"]}`))
+
+	err := conf.Validate(fs)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	want := []string{
+		"workers", "classes[1]", "sources[0].src", "sources[0].class_name_sync.bus",
+		"sources[1].format", "sources[1].src", "sources[1].class_name_sync",
+	}
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errs), len(want), err)
+	}
+	for i, e := range errs {
+		if p := e.(ValidationError).Path; p != want[i] {
+			t.Errorf("error %d path = %q, want %q", i, p, want[i])
+		}
+	}
+}
@@ -1,30 +1,182 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path"
 
+	"github.com/evilmagics/dataset_collector/internal/utils"
 	"github.com/goccy/go-json"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/afero"
 	"gopkg.in/yaml.v3"
 )
 
+// Provenance of a dataset export, as found on roboflow block of data.yaml
+type Provenance struct {
+	Workspace string `yaml:"workspace,omitempty" json:"workspace,omitempty"`
+	Project   string `yaml:"project,omitempty" json:"project,omitempty"`
+	Version   string `yaml:"version,omitempty" json:"version,omitempty"`
+	License   string `yaml:"license,omitempty" json:"license,omitempty"`
+	URL       string `yaml:"url,omitempty" json:"url,omitempty"`
+}
+
+// SplitPaths are image directories and .txt image lists of a split,
+// declared on data.yaml as a single path or a list of paths
+type SplitPaths []string
+
+func (p *SplitPaths) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var s string
+		if err := value.Decode(&s); err != nil {
+			return err
+		}
+		*p = nil
+		if s != "" {
+			*p = SplitPaths{s}
+		}
+		return nil
+	}
+
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*p = list
+	return nil
+}
+
+func (p SplitPaths) MarshalYAML() (interface{}, error) {
+	if len(p) == 1 {
+		return p[0], nil
+	}
+	return []string(p), nil
+}
+
+// Class names forms of data.yaml
+const (
+	NamesList = "list"
+	NamesMap  = "map"
+)
+
+// ClassNames are class names indexed by class id, empty for ids missing from sparse names.
+// Decoded from data.yaml as a list, or as a map of class id to name.
+type ClassNames []string
+
+func (n *ClassNames) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		*n = list
+		return nil
+	}
+
+	var m map[int]string
+	if err := value.Decode(&m); err != nil {
+		return err
+	}
+	names, err := SparseNames(m)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*n = names
+	return nil
+}
+
+// MaxClassId bounds class ids of sparse names, as names are allocated up to the highest id
+const MaxClassId = 1<<16 - 1
+
+// SparseNames returns class names of names keyed by class id, empty for missing ids.
+// Negative ids and ids over MaxClassId are rejected.
+func SparseNames(m map[int]string) (ClassNames, error) {
+	var names ClassNames
+	for id, name := range m {
+		if id < 0 || id > MaxClassId {
+			return nil, fmt.Errorf("class id %d out of range 0 to %d", id, MaxClassId)
+		}
+		if id >= len(names) {
+			names = append(names, make(ClassNames, id-len(names)+1)...)
+		}
+		names[id] = name
+	}
+	return names, nil
+}
+
+// Map returns class names keyed by class id, without missing ids
+func (n ClassNames) Map() map[int]string {
+	m := make(map[int]string, len(n))
+	for id, name := range n {
+		if name != "" {
+			m[id] = name
+		}
+	}
+	return m
+}
+
 type Dataset struct {
-	Train      string   `yaml:"train" json:"train"`
-	Valid      string   `yaml:"valid" json:"valid"`
-	Test       string   `yaml:"test" json:"test"`
-	NamesCount int      `yaml:"nc" json:"nc"`
-	Names      []string `yaml:"names" json:"names"`
+	Train      SplitPaths `yaml:"train" json:"train"`
+	Valid      SplitPaths `yaml:"valid" json:"valid"`
+	Test       SplitPaths `yaml:"test" json:"test"`
+	NamesCount int        `yaml:"nc" json:"nc"`
+	Names      ClassNames `yaml:"names" json:"names"`
 	namesIndex map[string]int
+	// Path is dataset root which split paths are relative to, defaults to data.yaml directory
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+	// Val is Ultralytics key of valid split, preferred over valid
+	Val SplitPaths `yaml:"val,omitempty" json:"val,omitempty"`
+	// NamesForm writes names as list or as map of class id, as decoded when loaded. Defaults to list.
+	NamesForm string `yaml:"-" json:"-"`
+	// Roboflow export metadata of source dataset
+	Roboflow *Provenance `yaml:"roboflow,omitempty" json:"roboflow,omitempty"`
+	// License, URL and Version of source dataset exported without roboflow block
+	License string `yaml:"license,omitempty" json:"license,omitempty"`
+	URL     string `yaml:"url,omitempty" json:"url,omitempty"`
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+}
+
+// Provenance returns provenance of dataset, from roboflow block completed by top level fields
+func (c Dataset) Provenance() Provenance {
+	var p Provenance
+	if c.Roboflow != nil {
+		p = *c.Roboflow
+	}
+	if p.License == "" {
+		p.License = c.License
+	}
+	if p.URL == "" {
+		p.URL = c.URL
+	}
+	if p.Version == "" {
+		p.Version = c.Version
+	}
+	return p
 }
 
 func (c Dataset) Debug() {
 	log.Debug().Any("Names Index", c.namesIndex).Any("Conf", c).Send()
 }
 
+// Splits returns paths declared for every split, none when data.yaml declares no split
+func (c Dataset) Splits() map[utils.Category]SplitPaths {
+	splits := make(map[utils.Category]SplitPaths)
+	if len(c.Train) > 0 {
+		splits[utils.CategoryTrain] = c.Train
+	}
+	if len(c.Val) > 0 {
+		splits[utils.CategoryValid] = c.Val
+	} else if len(c.Valid) > 0 {
+		splits[utils.CategoryValid] = c.Valid
+	}
+	if len(c.Test) > 0 {
+		splits[utils.CategoryTest] = c.Test
+	}
+	return splits
+}
+
 func (c Dataset) GetClassName(id int) string {
-	if id > len(c.Names)-1 {
+	if id < 0 || id > len(c.Names)-1 {
 		return ""
 	}
 	return c.Names[id]
@@ -45,16 +197,39 @@ func (c Dataset) YAMLMarshal() ([]byte, error) {
 	return yaml.Marshal(c)
 }
 
+// MarshalYAML writes names in the form of dataset
+func (c Dataset) MarshalYAML() (interface{}, error) {
+	type dataset Dataset
+	if c.NamesForm != NamesMap {
+		return dataset(c), nil
+	}
+
+	var node yaml.Node
+	if err := node.Encode(dataset(c)); err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "names" {
+			if err := node.Content[i+1].Encode(c.Names.Map()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &node, nil
+}
+
 func NewDataset(names ...string) *Dataset {
 	var namesIndex = make(map[string]int)
 	for i, n := range names {
-		namesIndex[n] = i
+		if n != "" {
+			namesIndex[n] = i
+		}
 	}
 
 	return &Dataset{
-		Train:      "../train/images",
-		Valid:      "../valid/images",
-		Test:       "../test/images",
+		Train:      SplitPaths{"../train/images"},
+		Valid:      SplitPaths{"../valid/images"},
+		Test:       SplitPaths{"../test/images"},
 		Names:      names,
 		NamesCount: len(names),
 		namesIndex: namesIndex,
@@ -70,9 +245,19 @@ func LoadDataset(fs afero.Fs, src string) (*Dataset, error) {
 	conf := new(Dataset)
 	err = yaml.Unmarshal(f, &conf)
 
+	// Keep names form, so dataset is written back as found
+	var form struct {
+		Names yaml.Node `yaml:"names"`
+	}
+	if yaml.Unmarshal(f, &form) == nil && form.Names.Kind == yaml.MappingNode {
+		conf.NamesForm = NamesMap
+	}
+
 	conf.namesIndex = make(map[string]int, len(conf.Names))
 	for i, n := range conf.Names {
-		conf.namesIndex[n] = i
+		if n != "" {
+			conf.namesIndex[n] = i
+		}
 	}
 	log.Info().Any("Names Index", conf.namesIndex).Msg("Create names index")
 
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestLoadDatasetNames(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "list.yaml", []byte("names: [person, bicycle]\n"), 0644)
+	afero.WriteFile(fs, "map.yaml", []byte("names:\n  0: person\n  3: car\n  1: bicycle\n"), 0644)
+
+	list, err := LoadDataset(fs, "list.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.NamesForm != "" || list.GetClassName(1) != "bicycle" {
+		t.Errorf("got form %q class 1 %q, want list with bicycle", list.NamesForm, list.GetClassName(1))
+	}
+
+	sparse, err := LoadDataset(fs, "map.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sparse.NamesForm != NamesMap {
+		t.Errorf("got form %q, want map", sparse.NamesForm)
+	}
+	for id, want := range map[int]string{0: "person", 1: "bicycle", 2: "", 3: "car", 4: "", -1: ""} {
+		if got := sparse.GetClassName(id); got != want {
+			t.Errorf("class %d got %q, want %q", id, got, want)
+		}
+	}
+	if sparse.GetClassId("car") != 3 {
+		t.Errorf("car got id %d, want 3", sparse.GetClassId("car"))
+	}
+
+	// Map form is written back without missing ids
+	b, err := sparse.YAMLMarshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "names:\n    0: person\n    1: bicycle\n    3: car\n") {
+		t.Errorf("got names written as:\n%s", b)
+	}
+	sparse.NamesForm = NamesList
+	if b, _ = sparse.YAMLMarshal(); !strings.Contains(string(b), "names:\n    - person\n") {
+		t.Errorf("got names written as:\n%s", b)
+	}
+
+	afero.WriteFile(fs, "negative.yaml", []byte("names: {-1: person}\n"), 0644)
+	if _, err := LoadDataset(fs, "negative.yaml"); err == nil {
+		t.Error("negative class id accepted")
+	}
+	afero.WriteFile(fs, "huge.yaml", []byte("names: {2000000000: person}\n"), 0644)
+	if _, err := LoadDataset(fs, "huge.yaml"); err == nil {
+		t.Error("class id over MaxClassId accepted")
+	}
+}
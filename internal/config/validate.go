@@ -0,0 +1,233 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/spf13/afero"
+)
+
+// ValidationError is a config problem found on key path
+type ValidationError struct {
+	Path string
+	Msg  string
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+// sha256Pattern matches hex encoded SHA-256 checksum
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+func isFormat(format string) bool {
+	switch format {
+	case "", FormatYOLO, FormatCOCO, FormatVOC:
+		return true
+	}
+	return false
+}
+
+func isLabelType(labelType string) bool {
+	switch labelType {
+	case "", LabelTypeDetect, LabelTypeSegment, LabelTypeOBB:
+		return true
+	}
+	return false
+}
+
+// Validate checks config and every source up front, without reading any dataset item.
+// Returns every problem found joined as ValidationError, or nil when config is valid.
+func (c Config) Validate(fs ...afero.Fs) error {
+	filesystem := afero.NewOsFs()
+	if len(fs) > 0 {
+		filesystem = fs[0]
+	}
+
+	var errs []error
+	invalid := func(path, format string, args ...any) {
+		errs = append(errs, ValidationError{Path: path, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Dest == "" {
+		invalid("dest", "destination directory is required")
+	}
+	if !isFormat(c.OutputFormat) {
+		invalid("output_format", "unsupported format %q", c.OutputFormat)
+	}
+	if !isLabelType(c.LabelType) {
+		invalid("label_type", "unsupported label type %q", c.LabelType)
+	} else if (c.LabelType == LabelTypeSegment || c.LabelType == LabelTypeOBB) && c.OutputFormat != "" && c.OutputFormat != FormatYOLO {
+		invalid("label_type", "output format %q holds %s labels only", c.OutputFormat, LabelTypeDetect)
+	}
+	switch c.LinkMode {
+	case "", LinkCopy, LinkHardlink, LinkSymlink, LinkReflink:
+	default:
+		invalid("link_mode", "unsupported link mode %q", c.LinkMode)
+	}
+	switch c.NamesForm {
+	case "", NamesList, NamesMap:
+	default:
+		invalid("names_form", "unsupported names form %q", c.NamesForm)
+	}
+	if c.Archive.MaxSize < 0 || c.Archive.MaxFiles < 0 {
+		invalid("archive", "limits must not be negative")
+	}
+	if c.Download.Retries < -1 {
+		invalid("download.retries", "must be -1 to disable retries or greater, got %d", c.Download.Retries)
+	}
+	if c.Workers <= 0 {
+		invalid("workers", "must be greater than 0, got %d", c.Workers)
+	}
+
+	switch c.Dedup.Policy {
+	case "", DedupKeepFirst, DedupKeepLargest:
+	default:
+		invalid("dedup.policy", "unsupported policy %q", c.Dedup.Policy)
+	}
+	if c.Dedup.Threshold < -1 || c.Dedup.Threshold > 64 {
+		invalid("dedup.threshold", "must be between -1 and 64, got %d", c.Dedup.Threshold)
+	}
+
+	switch c.Leakage.Action {
+	case "", LeakageReport, LeakageMove:
+	default:
+		invalid("leakage.action", "unsupported action %q", c.Leakage.Action)
+	}
+	if c.Leakage.Threshold < -1 || c.Leakage.Threshold > 64 {
+		invalid("leakage.threshold", "must be between -1 and 64, got %d", c.Leakage.Threshold)
+	}
+
+	if c.Split.Enabled {
+		if c.Split.Train < 0 || c.Split.Valid < 0 || c.Split.Test < 0 {
+			invalid("split", "ratios must not be negative")
+		} else if c.Split.Train+c.Split.Valid+c.Split.Test <= 0 {
+			invalid("split", "at least one ratio must be greater than 0")
+		}
+	}
+
+	switch c.Labels.Mode {
+	case "", LabelReject, LabelClip, LabelDropObject:
+	default:
+		invalid("labels.mode", "unsupported mode %q", c.Labels.Mode)
+	}
+
+	if c.Transform.Enabled {
+		switch c.Transform.Format {
+		case "", ImageJPEG, ImagePNG, ImageWebP:
+		default:
+			invalid("transform.format", "unsupported image format %q", c.Transform.Format)
+		}
+		if c.Transform.MaxSide < 0 {
+			invalid("transform.max_side", "must not be negative, got %d", c.Transform.MaxSide)
+		}
+		if l := c.Transform.Letterbox; l.Width < 0 || l.Height < 0 || (l.Width == 0) != (l.Height == 0) {
+			invalid("transform.letterbox", "width and height must be both greater than 0, got %dx%d", l.Width, l.Height)
+		}
+		if c.Transform.Quality < 0 || c.Transform.Quality > 100 {
+			invalid("transform.quality", "must be between 1 and 100, got %d", c.Transform.Quality)
+		}
+		switch c.Transform.Orient {
+		case "", OrientStored, OrientDisplayed:
+		default:
+			invalid("transform.orient", "unsupported orientation %q", c.Transform.Orient)
+		}
+	}
+
+	if c.Group.Pattern != "" {
+		if _, err := regexp.Compile(c.Group.Pattern); err != nil {
+			invalid("group.pattern", "%v", err)
+		}
+	}
+
+	classes := make(map[string]bool, len(c.Classes))
+	if len(c.Classes) == 0 {
+		invalid("classes", "at least one class is required")
+	}
+	for i, cls := range c.Classes {
+		if cls == "" {
+			invalid(fmt.Sprintf("classes[%d]", i), "class name is empty")
+		} else if classes[cls] {
+			invalid(fmt.Sprintf("classes[%d]", i), "duplicate class %q", cls)
+		}
+		classes[cls] = true
+	}
+
+	if c.Balance.Enabled {
+		names := make([]string, 0, len(c.Balance.Classes))
+		for cls := range c.Balance.Classes {
+			names = append(names, cls)
+		}
+		sort.Strings(names)
+
+		for _, cls := range names {
+			var (
+				b   = c.Balance.Classes[cls]
+				key = fmt.Sprintf("balance.classes.%s", cls)
+			)
+			if !classes[cls] {
+				invalid(key, "class %q is not listed on classes", cls)
+			}
+			if b.MaxObjects < 0 || b.MaxImages < 0 || b.MinImages < 0 {
+				invalid(key, "limits must not be negative")
+			} else if b.MaxImages > 0 && b.MinImages > b.MaxImages {
+				invalid(key, "min_images %d exceeds max_images %d", b.MinImages, b.MaxImages)
+			}
+		}
+	}
+
+	if len(c.Sources) == 0 {
+		invalid("sources", "at least one source is required")
+	}
+	for i, s := range c.Sources {
+		key := fmt.Sprintf("sources[%d]", i)
+
+		if !isFormat(s.Format) {
+			invalid(key+".format", "unsupported format %q", s.Format)
+		}
+		if !isLabelType(s.LabelType) {
+			invalid(key+".label_type", "unsupported label type %q", s.LabelType)
+		}
+
+		if s.URL != "" {
+			if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				invalid(key+".url", "invalid http url %q", s.URL)
+			}
+			if s.SHA256 != "" && !sha256Pattern.MatchString(s.SHA256) {
+				invalid(key+".sha256", "expected 64 hex characters")
+			}
+			if s.Src != "" {
+				invalid(key+".src", "src and url are exclusive")
+			}
+		} else if s.Src == "" {
+			invalid(key+".src", "source directory is required")
+		} else if utils.IsArchive(s.Src) {
+			if ok, _ := afero.Exists(filesystem, s.Src); !ok {
+				invalid(key+".src", "archive %q not found", s.Src)
+			}
+		} else if ok, _ := afero.DirExists(filesystem, s.Src); !ok {
+			invalid(key+".src", "directory %q not found", s.Src)
+		} else if s.Format == "" || s.Format == FormatYOLO {
+			if ok, _ := afero.Exists(filesystem, path.Join(s.Src, "data.yaml")); !ok {
+				invalid(key+".src", "data.yaml not found on %q", s.Src)
+			}
+		}
+
+		targets := s.ClassSync.Targets()
+		if len(targets) == 0 {
+			invalid(key+".class_name_sync", "no class mapped, source would collect nothing")
+		}
+		for _, t := range targets {
+			if !classes[t] {
+				invalid(key+".class_name_sync."+t, "target class not found on classes")
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// Attribution files written on destination root
+const (
+	AttributionFile = "ATTRIBUTION.md"
+	SourcesFile     = "sources.json"
+)
+
+// LicenseUnknown is written for sources declaring no license
+const LicenseUnknown = "unknown"
+
+// SourceAttribution is provenance of a collected source, with count of images it contributed
+type SourceAttribution struct {
+	// Source is url or src of source, as configured
+	Source string `json:"source"`
+	Format string `json:"format"`
+	config.Provenance
+	Images int `json:"images"`
+}
+
+// Name returns project name of source, or source itself when unknown
+func (s SourceAttribution) Name() string {
+	name := s.Source
+	if s.Project != "" {
+		name = s.Project
+		if s.Workspace != "" {
+			name = s.Workspace + "/" + s.Project
+		}
+	}
+	if s.Version != "" {
+		name += " v" + s.Version
+	}
+	return name
+}
+
+// attribution counts images contributed by every configured source, keyed by its url or src
+type attribution struct {
+	mu      *sync.Mutex
+	sources []SourceAttribution
+	index   map[string]int
+	// existing sources of destination appended onto, not configured anymore
+	existing []SourceAttribution
+}
+
+// sourceKey returns url or src of source as configured, before being downloaded or mounted
+func sourceKey(s config.Source) string {
+	if s.URL != "" {
+		return s.URL
+	}
+	return s.Src
+}
+
+func newAttribution(sources []config.Source) *attribution {
+	a := &attribution{mu: new(sync.Mutex), index: make(map[string]int)}
+	for _, s := range sources {
+		key := sourceKey(s)
+		if _, ok := a.index[key]; ok {
+			continue
+		}
+		format := s.Format
+		if format == "" {
+			format = config.FormatYOLO
+		}
+		a.index[key] = len(a.sources)
+		a.sources = append(a.sources, SourceAttribution{Source: key, Format: format})
+	}
+	return a
+}
+
+// provenance records provenance of source, loaded from its dataset config
+func (a *attribution) provenance(key string, dataset *config.Dataset) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i, ok := a.index[key]
+	if !ok {
+		return
+	}
+	a.sources[i].Provenance = dataset.Provenance()
+	if a.sources[i].License == "" {
+		log.Warn().Str("source", key).Msg("Source declares no license, check its terms before publishing")
+	}
+}
+
+// contributed counts images collected from their source, either on this run or recorded on manifest
+func (a *attribution) contributed(sources ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, key := range sources {
+		if i, ok := a.index[key]; ok {
+			a.sources[i].Images++
+		}
+	}
+}
+
+// merge keeps attribution of destination appended onto for sources not configured anymore
+func (a *attribution) merge(existing []SourceAttribution) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, e := range existing {
+		if _, ok := a.index[e.Source]; !ok {
+			a.existing = append(a.existing, e)
+		}
+	}
+}
+
+// Sources returns attribution of every source, existing ones first
+func (a *attribution) Sources() []SourceAttribution {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	sources := make([]SourceAttribution, 0, len(a.existing)+len(a.sources))
+	sources = append(sources, a.existing...)
+	return append(sources, a.sources...)
+}
+
+// LoadAttribution reads sources attribution written on dest, none when not found
+func LoadAttribution(fs afero.Fs, dest string) ([]SourceAttribution, error) {
+	b, err := afero.ReadFile(fs, path.Join(dest, SourcesFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []SourceAttribution
+	if err := json.Unmarshal(b, &sources); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// SaveAttribution writes sources attribution on dest, as JSON and as markdown table
+func SaveAttribution(fs afero.Fs, sources []SourceAttribution, dest string) error {
+	b, err := json.MarshalIndent(sources, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(fs, path.Join(dest, SourcesFile), b); err != nil {
+		return err
+	}
+
+	var md strings.Builder
+	md.WriteString("# Attribution\n\nThis dataset merges images from the following sources.\n\n")
+	md.WriteString("| Source | License | Images | URL |\n| --- | --- | ---: | --- |\n")
+	for _, s := range sources {
+		license := s.License
+		if license == "" {
+			license = LicenseUnknown
+		}
+		fmt.Fprintf(&md, "| %s | %s | %d | %s |\n", markdownCell(s.Name()), markdownCell(license), s.Images, markdownCell(s.URL))
+	}
+	return writeFile(fs, path.Join(dest, AttributionFile), []byte(md.String()))
+}
+
+// markdownCell escapes text written on markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+)
+
+func TestCollectAttribution(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	roboflow := testYOLOSource(t, fs, "roboflow", map[string][]string{"train": {"a", "b"}})
+	afero.WriteFile(fs, "roboflow/data.yaml", []byte(`names: ["bus", "car", "van"]
+roboflow:
+  workspace: thilan
+  project: vehicles
+  version: 1
+  license: CC BY 4.0
+  url: https://universe.roboflow.com/thilan/vehicles/dataset/1
+`), 0644)
+
+	c := testCollector(t, fs, &config.Config{Dest: "dst", Sources: []config.Source{roboflow}})
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Appended source is credited besides the existing one
+	local := testYOLOSource(t, fs, "local", map[string][]string{"valid": {"c"}})
+	c = testCollector(t, fs, &config.Config{Dest: "dst", Append: true, Sources: []config.Source{local}})
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	sources, err := LoadAttribution(fs, "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 {
+		t.Fatalf("got %d sources, want 2", len(sources))
+	}
+	want := SourceAttribution{
+		Source: "roboflow",
+		Format: config.FormatYOLO,
+		Provenance: config.Provenance{
+			Workspace: "thilan",
+			Project:   "vehicles",
+			Version:   "1",
+			License:   "CC BY 4.0",
+			URL:       "https://universe.roboflow.com/thilan/vehicles/dataset/1",
+		},
+		Images: 2,
+	}
+	if sources[0] != want {
+		t.Errorf("got %+v, want %+v", sources[0], want)
+	}
+	if sources[1].Source != "local" || sources[1].Images != 1 || sources[1].License != "" {
+		t.Errorf("got %+v, want local source of 1 image without license", sources[1])
+	}
+
+	md, _ := afero.ReadFile(fs, "dst/"+AttributionFile)
+	for _, row := range []string{
+		"| thilan/vehicles v1 | CC BY 4.0 | 2 | https://universe.roboflow.com/thilan/vehicles/dataset/1 |",
+		"| local | unknown | 1 |  |",
+	} {
+		if !strings.Contains(string(md), row) {
+			t.Errorf("attribution misses row %q:\n%s", row, md)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+)
+
+// errCapped reports item skipped as it would exceed a class cap
+var errCapped = errors.New("Item exceeds class cap")
+
+// balanceSample is a collected item holding an oversampled class, kept to collect its copies
+type balanceSample struct {
+	item    DatasetItem
+	classes CollectClasses
+	copies  int
+}
+
+// balancer counts collected objects and images of every destination class against configured caps,
+// keeping items of classes to oversample.
+type balancer struct {
+	mu      *sync.Mutex
+	limits  map[string]config.ClassBalance
+	objects CollectClasses
+	images  CollectClasses
+	samples map[string][]*balanceSample
+}
+
+// newBalancer returns balancer counting source items already collected on manifest entries,
+// so caps cover the whole destination on resume or append.
+func newBalancer(conf config.Balance, collected ...ManifestEntry) *balancer {
+	b := &balancer{
+		mu:      new(sync.Mutex),
+		limits:  conf.Classes,
+		objects: make(CollectClasses),
+		images:  make(CollectClasses),
+		samples: make(map[string][]*balanceSample),
+	}
+	for _, e := range collected {
+		if e.Copy > 0 {
+			continue
+		}
+		for cls, n := range e.Classes {
+			b.objects.Incr(cls, n)
+			b.images.Incr(cls)
+		}
+	}
+	return b
+}
+
+// reserve counts classes of item, unless it would exceed cap of any of its classes.
+// Items already collected are only sampled, as counted from manifest. Returns whether item is accepted.
+func (b *balancer) reserve(item *DatasetItem, classes CollectClasses, collected bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.reserveLocked(item, classes, collected)
+}
+
+func (b *balancer) reserveLocked(item *DatasetItem, classes CollectClasses, collected bool) bool {
+	if !collected {
+		for cls, n := range classes {
+			limit := b.limits[cls]
+			if limit.MaxObjects > 0 && b.objects[cls]+n > limit.MaxObjects {
+				return false
+			}
+			if limit.MaxImages > 0 && b.images[cls]+1 > limit.MaxImages {
+				return false
+			}
+		}
+	}
+
+	var sample *balanceSample
+	for cls, n := range classes {
+		if !collected {
+			b.objects.Incr(cls, n)
+			b.images.Incr(cls)
+		}
+
+		// Only source items are sampled, copies are not copied again
+		if b.limits[cls].MinImages == 0 || item.Copy > 0 {
+			continue
+		}
+		if sample == nil {
+			sample = &balanceSample{
+				item: DatasetItem{
+					Hash:   item.Hash,
+					SrcDir: item.SrcDir,
+					DstDir: item.DstDir,
+					Cat:    item.Cat,
+					Source: item.Source,
+					Image: &Item{
+						SrcFilename: item.Image.SrcFilename,
+						SrcPath:     item.Image.SrcPath,
+						Width:       item.Image.Width,
+						Height:      item.Image.Height,
+					},
+					Label: &Item{SrcFilename: item.Label.SrcFilename, SrcPath: item.Label.SrcPath, Data: item.Label.Data},
+				},
+				classes: classes,
+			}
+		}
+		b.samples[cls] = append(b.samples[cls], sample)
+	}
+	return true
+}
+
+// copies returns copies of samples, with their classes, to collect until every oversampled class
+// reaches its min images. Samples are taken in turn ordered by source path, so a resumed collection
+// makes the same copies. Copies are counted as reserved, skipping samples exceeding any cap.
+func (b *balancer) copies() ([]*DatasetItem, []CollectClasses) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names := make([]string, 0, len(b.samples))
+	for cls := range b.samples {
+		names = append(names, cls)
+	}
+	sort.Strings(names)
+
+	var (
+		items   []*DatasetItem
+		classes []CollectClasses
+	)
+	for _, cls := range names {
+		samples := b.samples[cls]
+		sort.Slice(samples, func(i, j int) bool { return samples[i].item.Image.SrcPath < samples[j].item.Image.SrcPath })
+
+		for b.images[cls] < b.limits[cls].MinImages {
+			accepted := false
+			for _, s := range samples {
+				if b.images[cls] >= b.limits[cls].MinImages {
+					break
+				}
+
+				item := s.item
+				item.Copy = s.copies + 1
+				// Copies keep size verified on sample, as image data is streamed on write
+				item.Image = &Item{
+					SrcFilename: s.item.Image.SrcFilename,
+					SrcPath:     s.item.Image.SrcPath,
+					Width:       s.item.Image.Width,
+					Height:      s.item.Image.Height,
+				}
+				item.Label = &Item{SrcFilename: s.item.Label.SrcFilename, SrcPath: s.item.Label.SrcPath, Data: s.item.Label.Data}
+				if !b.reserveLocked(&item, s.classes, false) {
+					continue
+				}
+
+				s.copies++
+				accepted = true
+				items = append(items, &item)
+				classes = append(classes, s.classes)
+			}
+			if !accepted {
+				break
+			}
+		}
+	}
+	return items, classes
+}
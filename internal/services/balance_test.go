@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+)
+
+func TestCollectBalance(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	src := testYOLOSource(t, fs, "src", map[string][]string{"train": {"a", "b", "c", "d", "e", "bus"}})
+	afero.WriteFile(fs, "src/train/labels/bus.txt", []byte("0 0.5 0.5 0.2 0.2"), 0644)
+
+	conf := &config.Config{
+		Dest:    "dst",
+		Sources: []config.Source{src},
+		Balance: config.Balance{Enabled: true, Classes: map[string]config.ClassBalance{
+			"car": {MaxImages: 3},
+			"bus": {MinImages: 3},
+		}},
+	}
+	c := testCollector(t, fs, conf)
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := c.summary["train"]
+	if sum.Capped != 2 || sum.Oversampled != 2 || sum.Success != 6 {
+		t.Errorf("got capped %d oversampled %d success %d, want 2, 2 and 6", sum.Capped, sum.Oversampled, sum.Success)
+	}
+	if sum.Classes["car"] != 6 || sum.Classes["bus"] != 3 {
+		t.Errorf("got classes %s, want bus:3 car:6", sum.Classes)
+	}
+
+	// Resumed collection keeps copies already written
+	conf.Resume = true
+	c = testCollector(t, fs, conf)
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+	if sum := c.summary["train"]; sum.Skipped != 6 || sum.Success != 0 {
+		t.Errorf("resumed got skipped %d success %d, want 6 and 0", sum.Skipped, sum.Success)
+	}
+	if n := c.manifest.Len(); n != 6 {
+		t.Errorf("manifest has %d entries, want 6", n)
+	}
+}
+
+func TestCollectBalanceFormats(t *testing.T) {
+	for _, format := range []string{config.FormatCOCO, config.FormatVOC} {
+		t.Run(format, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			src := testYOLOSource(t, fs, "src", map[string][]string{"train": {"a", "bus"}})
+			afero.WriteFile(fs, "src/train/labels/bus.txt", []byte("0 0.5 0.5 0.2 0.2"), 0644)
+
+			// Streamed copies are written with size verified on their sample
+			c := testCollector(t, fs, &config.Config{
+				Dest:         "dst",
+				OutputFormat: format,
+				Sources:      []config.Source{src},
+				Balance: config.Balance{Enabled: true, Classes: map[string]config.ClassBalance{
+					"bus": {MinImages: 3},
+				}},
+			})
+			if _, err := c.CollectAll(); err != nil {
+				t.Fatal(err)
+			}
+
+			sum := c.summary["train"]
+			if sum.Success != 4 || sum.Oversampled != 2 || sum.Failed != 0 {
+				t.Errorf("got success %d oversampled %d failed %d, want 4, 2 and 0", sum.Success, sum.Oversampled, sum.Failed)
+			}
+		})
+	}
+}
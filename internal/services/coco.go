@@ -0,0 +1,389 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// CocoAnnotationsFile is the annotation filename expected on each category folder of COCO sources
+const CocoAnnotationsFile = "_annotations.coco.json"
+
+type CocoImage struct {
+	Id       int    `json:"id"`
+	FileName string `json:"file_name"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+type CocoAnnotation struct {
+	Id         int       `json:"id"`
+	ImageId    int       `json:"image_id"`
+	CategoryId int       `json:"category_id"`
+	Bbox       []float64 `json:"bbox"`
+	Area       float64   `json:"area"`
+	IsCrowd    int       `json:"iscrowd"`
+}
+
+type CocoCategory struct {
+	Id            int    `json:"id"`
+	Name          string `json:"name"`
+	Supercategory string `json:"supercategory"`
+}
+
+// CocoInfo describes the dataset of a COCO annotations file
+type CocoInfo struct {
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Contributor string `json:"contributor,omitempty"`
+}
+
+type CocoLicense struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type Coco struct {
+	Images      []CocoImage      `json:"images"`
+	Annotations []CocoAnnotation `json:"annotations"`
+	Categories  []CocoCategory   `json:"categories"`
+	// Info and Licenses of source dataset, kept as its provenance
+	Info     *CocoInfo     `json:"info,omitempty"`
+	Licenses []CocoLicense `json:"licenses,omitempty"`
+}
+
+func LoadCoco(fs afero.Fs, src string) (*Coco, error) {
+	f, err := afero.ReadFile(fs, src)
+	if err != nil {
+		return nil, err
+	}
+
+	coco := new(Coco)
+	if err := json.Unmarshal(f, coco); err != nil {
+		return nil, err
+	}
+	return coco, nil
+}
+
+// YOLOLabels converts annotations of each image to normalized YOLO label lines.
+// Category id is used as class index, keyed by image id.
+func (c Coco) YOLOLabels() map[int][]string {
+	sizes := make(map[int]CocoImage, len(c.Images))
+	for _, img := range c.Images {
+		sizes[img.Id] = img
+	}
+
+	labels := make(map[int][]string)
+	for _, a := range c.Annotations {
+		img, ok := sizes[a.ImageId]
+		if !ok || img.Width <= 0 || img.Height <= 0 || len(a.Bbox) != 4 {
+			log.Warn().Int("annotation", a.Id).Int("image", a.ImageId).Msg("Skip invalid COCO annotation")
+			continue
+		}
+
+		var (
+			w  = float64(img.Width)
+			h  = float64(img.Height)
+			cx = (a.Bbox[0] + a.Bbox[2]/2) / w
+			cy = (a.Bbox[1] + a.Bbox[3]/2) / h
+		)
+		labels[a.ImageId] = append(labels[a.ImageId],
+			fmt.Sprintf("%d %.6f %.6f %.6f %.6f", a.CategoryId, cx, cy, a.Bbox[2]/w, a.Bbox[3]/h))
+	}
+	return labels
+}
+
+// cocoReader reads COCO layout sources: `<category>/_annotations.coco.json`
+// with images placed alongside the annotations file.
+type cocoReader struct{}
+
+// dirs returns every category folder on source root holding a COCO annotations file
+func (cocoReader) dirs(fs afero.Fs, src string) (map[string]utils.Category, error) {
+	entry, err := afero.ReadDir(fs, src)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]utils.Category)
+	for _, e := range entry {
+		if !e.IsDir() {
+			continue
+		}
+		cat := utils.FindCategory(e.Name())
+		if cat == nil {
+			continue
+		}
+
+		dir := path.Join(src, e.Name())
+		if ok, _ := afero.Exists(fs, path.Join(dir, CocoAnnotationsFile)); ok {
+			dirs[dir] = *cat
+		}
+	}
+	return dirs, nil
+}
+
+// LoadDatasetConfig builds source class names from categories of every annotations file.
+// Class index follows the COCO category id, provenance is taken from info and licenses.
+func (r cocoReader) LoadDatasetConfig(fs afero.Fs, src *config.Source) error {
+	dirs, err := r.dirs(fs, src.Src)
+	if err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		return fmt.Errorf("no %s found on %s", CocoAnnotationsFile, src.Src)
+	}
+
+	var (
+		categories = make(map[int]string)
+		provenance config.Provenance
+	)
+	for dir := range dirs {
+		coco, err := LoadCoco(fs, path.Join(dir, CocoAnnotationsFile))
+		if err != nil {
+			return err
+		}
+		if coco.Info != nil {
+			provenance.URL = coco.Info.URL
+		}
+		if len(coco.Licenses) > 0 {
+			provenance.License = coco.Licenses[0].Name
+		}
+		for _, cat := range coco.Categories {
+			if name, ok := categories[cat.Id]; ok && name != cat.Name {
+				return fmt.Errorf("category %d named both %q and %q", cat.Id, name, cat.Name)
+			}
+			categories[cat.Id] = cat.Name
+		}
+	}
+
+	if len(categories) == 0 {
+		return fmt.Errorf("no COCO categories found on %s", src.Src)
+	}
+
+	names, err := config.SparseNames(categories)
+	if err != nil {
+		return fmt.Errorf("COCO categories of %s: %w", src.Src, err)
+	}
+	src.DatasetConfig = config.NewDataset(names...)
+	src.DatasetConfig.License = provenance.License
+	src.DatasetConfig.URL = provenance.URL
+
+	return nil
+}
+
+func (r cocoReader) Items(fs afero.Fs, src config.Source, dest string) ([]*DatasetItem, error) {
+	dirs, err := r.dirs(fs, src.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*DatasetItem
+	for dir, cat := range dirs {
+		annotations := path.Join(dir, CocoAnnotationsFile)
+		coco, err := LoadCoco(fs, annotations)
+		if err != nil {
+			log.Warn().Err(err).Str("src", annotations).Msg("Failed read COCO annotations")
+			continue
+		}
+
+		log.Info().Any("name", cat).Str("Path", dir).Msg("Collecting dataset on folder.")
+		labels := coco.YOLOLabels()
+		for _, img := range coco.Images {
+			items = append(items, &DatasetItem{
+				SrcDir: dir,
+				DstDir: path.Join(dest, string(cat)),
+				Cat:    cat,
+				Image: &Item{
+					SrcFilename: path.Base(img.FileName),
+					SrcPath:     path.Join(dir, img.FileName),
+				},
+				Label: &Item{
+					SrcFilename: CocoAnnotationsFile,
+					SrcPath:     annotations,
+					Data:        []byte(strings.Join(labels[img.Id], "\n")),
+				},
+			})
+		}
+	}
+
+	return items, nil
+}
+
+// cocoWriter writes COCO layout destination: `<category>/_annotations.coco.json`
+// with images placed alongside, annotations are kept in memory until Close.
+type cocoWriter struct {
+	mu         *sync.Mutex
+	dest       string
+	categories []CocoCategory
+	splits     map[utils.Category]*Coco
+	// indexed holds image ids of every split, so images already loaded are indexed once
+	indexed map[utils.Category]map[int]bool
+}
+
+func newCocoWriter() *cocoWriter {
+	return &cocoWriter{
+		mu:      new(sync.Mutex),
+		splits:  make(map[utils.Category]*Coco),
+		indexed: make(map[utils.Category]map[int]bool),
+	}
+}
+
+// Load keeps annotations of every category already written on dest, with classes from its categories.
+func (w *cocoWriter) Load(fs afero.Fs, dest string) (*config.Dataset, error) {
+	var names []string
+	for _, cat := range []utils.Category{utils.CategoryTrain, utils.CategoryTest, utils.CategoryValid} {
+		src := path.Join(dest, string(cat), CocoAnnotationsFile)
+		if ok, _ := afero.Exists(fs, src); !ok {
+			continue
+		}
+
+		coco, err := LoadCoco(fs, src)
+		if err != nil {
+			return nil, err
+		}
+		w.mu.Lock()
+		w.splits[cat] = coco
+		w.indexed[cat] = make(map[int]bool, len(coco.Images))
+		for _, img := range coco.Images {
+			w.indexed[cat][img.Id] = true
+		}
+		w.mu.Unlock()
+
+		// Category ids may be sparse, e.g. starting from 1, missing ids are kept as empty names
+		if names == nil {
+			categories := make(map[int]string, len(coco.Categories))
+			for _, c := range coco.Categories {
+				categories[c.Id] = c.Name
+			}
+			if names, err = config.SparseNames(categories); err != nil {
+				return nil, fmt.Errorf("%s: %w", src, err)
+			}
+			if names == nil {
+				names = []string{}
+			}
+		}
+	}
+
+	if names == nil {
+		return nil, nil
+	}
+	return config.NewDataset(names...), nil
+}
+
+func (w *cocoWriter) CreateDestFolder(fs afero.Fs, dest string) error {
+	for _, cat := range []string{utils.CategoryTrain, utils.CategoryTest, utils.CategoryValid} {
+		if err := fs.MkdirAll(path.Join(dest, cat), os.ModePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateConfig keeps destination classes as COCO categories, with class index as category id.
+func (w *cocoWriter) CreateConfig(fs afero.Fs, dataset config.Dataset, dest string) error {
+	w.dest = dest
+	w.categories = make([]CocoCategory, 0, len(dataset.Names))
+	for i, n := range dataset.Names {
+		// Sparse class ids have no category
+		if n == "" {
+			continue
+		}
+		w.categories = append(w.categories, CocoCategory{Id: i, Name: n, Supercategory: "none"})
+	}
+
+	// Loaded splits follow destination categories, which may extend loaded ones
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, split := range w.splits {
+		split.Categories = w.categories
+	}
+	return nil
+}
+
+func (w *cocoWriter) SetDestPaths(item *DatasetItem) {
+	item.Image.DstPath = path.Join(item.DstDir, item.Image.DstFilename)
+	item.Label.DstFilename = CocoAnnotationsFile
+	item.Label.DstPath = path.Join(item.DstDir, CocoAnnotationsFile)
+}
+
+func (w *cocoWriter) Write(fs afero.Fs, item *DatasetItem) error {
+	if err := writeImage(fs, item.Image); err != nil {
+		return err
+	}
+	if err := w.Index(item); err != nil {
+		fs.Remove(item.Image.DstPath)
+		return err
+	}
+	return nil
+}
+
+func (w *cocoWriter) Index(item *DatasetItem) error {
+	width, height, err := item.Image.Size()
+	if err != nil {
+		return err
+	}
+	boxes, err := ParseYOLOLabels(item.Label.Data)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Image already loaded from destination keeps its annotations
+	if w.indexed[item.Cat][item.Id] {
+		return nil
+	}
+	if w.indexed[item.Cat] == nil {
+		w.indexed[item.Cat] = make(map[int]bool)
+	}
+	w.indexed[item.Cat][item.Id] = true
+
+	split := w.splits[item.Cat]
+	if split == nil {
+		split = &Coco{Categories: w.categories}
+		w.splits[item.Cat] = split
+	}
+	split.Images = append(split.Images, CocoImage{
+		Id:       item.Id,
+		FileName: item.Image.DstFilename,
+		Width:    width,
+		Height:   height,
+	})
+	for _, b := range boxes {
+		bw, bh := b.W*float64(width), b.H*float64(height)
+		split.Annotations = append(split.Annotations, CocoAnnotation{
+			Id:         len(split.Annotations),
+			ImageId:    item.Id,
+			CategoryId: b.Class,
+			Bbox:       []float64{b.X*float64(width) - bw/2, b.Y*float64(height) - bh/2, bw, bh},
+			Area:       bw * bh,
+		})
+	}
+	return nil
+}
+
+func (w *cocoWriter) Close(fs afero.Fs) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for cat, split := range w.splits {
+		b, err := json.Marshal(split)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(fs, path.Join(w.dest, string(cat), CocoAnnotationsFile), b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+)
+
+const cocoSample = `{
+	"images": [{"id": 0, "file_name": "a.jpg", "width": 200, "height": 100}],
+	"annotations": [{"id": 0, "image_id": 0, "category_id": 2, "bbox": [50, 25, 100, 50]}],
+	"categories": [{"id": 0, "name": "vehicles"}, {"id": 2, "name": "car"}]
+}`
+
+func TestCocoReader(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "src/train/"+CocoAnnotationsFile, []byte(cocoSample), 0644)
+	afero.WriteFile(fs, "src/train/a.jpg", []byte("img"), 0644)
+
+	src := config.Source{Src: "src", Format: config.FormatCOCO}
+	reader, err := NewSourceReader(src.Format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.LoadDatasetConfig(fs, &src); err != nil {
+		t.Fatal(err)
+	}
+	if name := src.DatasetConfig.GetClassName(2); name != "car" {
+		t.Errorf("class 2 = %q, want car", name)
+	}
+
+	items, err := reader.Items(fs, src, "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	if got, want := string(items[0].Label.Data), "2 0.500000 0.500000 0.500000 0.500000"; got != want {
+		t.Errorf("label = %q, want %q", got, want)
+	}
+	if items[0].Image.SrcPath != "src/train/a.jpg" || items[0].Cat != "train" {
+		t.Errorf("unexpected item %+v", items[0].Image)
+	}
+}
+
+func TestCocoReaderInvalidCategory(t *testing.T) {
+	for _, id := range []string{"-1", "2000000000"} {
+		fs := afero.NewMemMapFs()
+		afero.WriteFile(fs, "src/train/"+CocoAnnotationsFile, []byte(`{"images": [], "annotations": [],
+			"categories": [{"id": `+id+`, "name": "car"}]}`), 0644)
+
+		src := config.Source{Src: "src", Format: config.FormatCOCO}
+		reader, _ := NewSourceReader(src.Format)
+		if err := reader.LoadDatasetConfig(fs, &src); err == nil {
+			t.Errorf("category id %s accepted", id)
+		}
+	}
+}
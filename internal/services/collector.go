@@ -3,8 +3,9 @@ package services
 import (
 	"errors"
 	"math"
-	"os"
 	"path"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -27,11 +28,34 @@ func (c *CollectClasses) Incr(cls string, count ...int) {
 	(*c)[cls]++
 }
 
+// String returns class counts sorted by class name, e.g. "car:3 truck:1"
+func (c CollectClasses) String() string {
+	classes := make([]string, 0, len(c))
+	for k, v := range c {
+		classes = append(classes, k+":"+strconv.Itoa(v))
+	}
+	sort.Strings(classes)
+	return strings.Join(classes, " ")
+}
+
 type CollectSummary struct {
 	mu      *sync.Mutex
 	Classes CollectClasses `json:"classes"`
 	Success int            `json:"success"`
 	Failed  int            `json:"failed"`
+	Skipped int            `json:"skipped"`
+	// Duplicates is count of images dropped as duplicate of another
+	Duplicates int `json:"duplicates"`
+	// Capped is count of images skipped as exceeding a class cap
+	Capped int `json:"capped"`
+	// Oversampled is count of copies collected for classes below their min images, included on success
+	Oversampled int `json:"oversampled"`
+	// Repaired is count of label objects clipped into image bounds
+	Repaired int `json:"repaired"`
+	// DroppedObjects is count of invalid label objects removed
+	DroppedObjects int `json:"dropped_objects"`
+	// Rejected is count of failed items by rejection reason, e.g. corrupt image
+	Rejected map[string]int `json:"rejected,omitempty"`
 }
 
 func (s *CollectSummary) success(cls CollectClasses) {
@@ -43,19 +67,68 @@ func (s *CollectSummary) success(cls CollectClasses) {
 		s.Classes.Incr(k, v)
 	}
 }
-func (s *CollectSummary) failed() {
+func (s *CollectSummary) skipped(cls CollectClasses) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Skipped++
+	for k, v := range cls {
+		s.Classes.Incr(k, v)
+	}
+}
+func (s *CollectSummary) oversampled(cls CollectClasses) {
+	s.success(cls)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Oversampled++
+}
+func (s *CollectSummary) validated(check LabelCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Repaired += check.Repaired
+	s.DroppedObjects += check.Dropped
+}
+func (s *CollectSummary) capped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Capped++
+}
+func (s *CollectSummary) duplicated() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Duplicates++
+}
+func (s *CollectSummary) failed(reason ...string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.Failed++
+	if len(reason) > 0 {
+		if s.Rejected == nil {
+			s.Rejected = make(map[string]int)
+		}
+		s.Rejected[reason[0]]++
+	}
 }
 
 func (s CollectSummary) Show(cat utils.Category) {
 	log.Info().
 		Any("_category", cat).
-		Int("count", s.Failed+s.Success).
+		Int("count", s.Failed+s.Success+s.Skipped).
 		Int("failed", s.Failed).
 		Int("success", s.Success).
+		Int("skipped", s.Skipped).
+		Int("duplicates", s.Duplicates).
+		Int("capped", s.Capped).
+		Int("oversampled", s.Oversampled).
+		Int("repaired", s.Repaired).
+		Int("droppedObjects", s.DroppedObjects).
+		Any("rejected", s.Rejected).
 		Any("xObjects", s.Classes).
 		Msg("Summary")
 }
@@ -68,18 +141,31 @@ func NewCollectSummary() *CollectSummary {
 		Classes: make(CollectClasses),
 		Success: 0,
 		Failed:  0,
+		Skipped: 0,
 	}
 }
 
+// errCollected reports item already written by a resumed collection
+var errCollected = errors.New("Item already collected")
+
 type Collector struct {
 	fs              afero.Fs
 	conf            *config.Config
 	datasetConf     *config.Dataset
+	output          OutputWriter
 	increments      map[utils.Category]*utils.Increment
 	pool            *ants.MultiPool
 	progressTotal   int64
 	currentProgress int64
 	summary         CategorizedSummary
+	plan            *Plan
+	manifest        *Manifest
+	grouper         *utils.Grouper
+	balance         *balancer
+	attribution     *attribution
+	// sources being collected, resolved from configured sources to downloaded and mounted archives
+	// with their dataset config, leaving configured sources as written
+	sources []config.Source
 }
 
 type Item struct {
@@ -88,15 +174,27 @@ type Item struct {
 	DstFilename string
 	DstPath     string
 	Data        []byte
+	// Width and Height of image recorded by verification
+	Width  int
+	Height int
+	// Ext overrides destination extension of image re-encoded into another format
+	Ext string
+	// Link mode of destination image to its source, copying Data when empty
+	Link string
 }
 
 type DatasetItem struct {
 	Id     int
+	Hash   string
 	SrcDir string
 	DstDir string
 	Label  *Item
 	Image  *Item
 	Cat    utils.Category
+	// Copy is number of oversampled copy of source item, zero for the source item itself
+	Copy int
+	// Source is url or src of source item was listed from, as configured
+	Source string
 }
 
 func (i *DatasetItem) SetNewFilename(id int) {
@@ -104,6 +202,9 @@ func (i *DatasetItem) SetNewFilename(id int) {
 		ext     = path.Ext(i.Image.SrcFilename)
 		newName = string(i.Cat) + "_" + strconv.Itoa(id)
 	)
+	if i.Image.Ext != "" {
+		ext = i.Image.Ext
+	}
 	i.Id = id
 	i.Image.DstFilename = utils.RealFilename(newName, ext)
 	i.Image.DstPath = utils.ImagePath(i.DstDir, utils.RealFilename(newName, ext))
@@ -112,6 +213,12 @@ func (i *DatasetItem) SetNewFilename(id int) {
 	i.Label.DstPath = utils.LabelPath(i.DstDir, utils.RealFilename(newName, ".txt"))
 }
 
+// SetCategory moves item into another destination category, before its new filename is set
+func (i *DatasetItem) SetCategory(cat utils.Category) {
+	i.DstDir = path.Join(path.Dir(i.DstDir), string(cat))
+	i.Cat = cat
+}
+
 func CreateDatasetItem(src, dst, imageFilename string, cat utils.Category) *DatasetItem {
 	var (
 		oldName = utils.Filename(imageFilename)
@@ -139,16 +246,40 @@ func CreateDatasetItem(src, dst, imageFilename string, cat utils.Category) *Data
 // It takes configuration and dataset configuration as parameters, with optional filesystem.
 // Returns a pointer to the newly created Collector.
 func NewCollector(conf *config.Config, fs ...afero.Fs) (*Collector, error) {
-	workers := int(math.Round(float64(conf.Workers) / 5))
+	workers := max(1, int(math.Round(float64(conf.Workers)/5)))
 	pool, err := ants.NewMultiPool(5, workers, ants.RoundRobin, ants.WithPreAlloc(false))
 	if err != nil {
 		return nil, err
 	}
 
+	output, err := NewOutputWriter(conf.OutputFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	var grouper *utils.Grouper
+	if conf.Group.Enabled {
+		pattern := conf.Group.Pattern
+		if pattern == "" {
+			pattern = utils.RoboflowGroupPattern
+		}
+		if grouper, err = utils.NewGrouper(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	filesystem := afero.NewOsFs()
+	if len(fs) > 0 {
+		filesystem = fs[0]
+	}
+
 	return &Collector{
-		fs:   afero.NewOsFs(),
-		conf: conf,
-		pool: pool,
+		fs:          filesystem,
+		conf:        conf,
+		pool:        pool,
+		output:      output,
+		grouper:     grouper,
+		attribution: newAttribution(conf.Sources),
 		increments: map[utils.Category]*utils.Increment{
 			utils.CategoryTest:  utils.NewIncrement(),
 			utils.CategoryTrain: utils.NewIncrement(),
@@ -166,8 +297,14 @@ func (c *Collector) createSummaries() {
 	for cat := range c.increments {
 		c.summary[cat] = NewCollectSummary()
 	}
+	if c.conf.DryRun {
+		c.plan = NewPlan(c.summary)
+	}
 }
 
+// Plan returns merge plan of the last dry-run collection, nil when not in dry-run mode.
+func (c *Collector) Plan() *Plan { return c.plan }
+
 // Collect orchestrates the dataset collection process by creating the destination folder,
 // generating the dataset configuration, and collecting data from each configured source.
 // It handles creating the destination directory, generating the data configuration file,
@@ -176,90 +313,305 @@ func (c *Collector) createSummaries() {
 func (c *Collector) CollectAll() (summary *CollectSummary, err error) {
 	c.createSummaries()
 	defer c.pool.Reboot()
+	defer c.unmountArchives()
+
+	if c.conf.DryRun {
+		// Dry-run only needs destination classes to sync labels
+		c.datasetConf = config.NewDataset(c.conf.Classes...)
+		if c.conf.Append {
+			if err := c.loadDestination(); err != nil {
+				log.Fatal().Err(err).Msg("Failed load existing destination")
+				return nil, err
+			}
+		}
 
-	// Create destination folder
-	if err = c.CreateDestFolder(); err != nil {
-		log.Fatal().Err(err).Msg("Failed create destination folder")
-		return nil, err
+		// Plan skips items already collected and numbers after them, as collection would
+		if c.conf.Resume || c.conf.Append {
+			if c.manifest, err = LoadManifest(c.fs, c.conf.Dest); err != nil {
+				log.Fatal().Err(err).Msg("Failed load manifest")
+				return nil, err
+			}
+			for cat, incr := range c.increments {
+				c.increments[cat] = utils.NewIncrement(max(incr.Current(), c.manifest.LastId(cat)))
+			}
+		}
+		log.Info().Str("dest", c.conf.Dest).Msg("Dry-run, no destination file will be written")
+	} else {
+		// Create destination folder
+		if err = c.CreateDestFolder(); err != nil {
+			log.Fatal().Err(err).Msg("Failed create destination folder")
+			return nil, err
+		}
+
+		// Keep dataset already written on destination
+		if c.conf.Append {
+			if err := c.loadDestination(); err != nil {
+				log.Fatal().Err(err).Msg("Failed load existing destination")
+				return nil, err
+			}
+		}
+
+		// Create dataset config on root destination folder
+		if err := c.CreateConfig(); err != nil {
+			log.Fatal().Err(err).Msg("Failed create dataset config!")
+			return nil, err
+		}
+		log.Info().Str("dest", c.conf.Dest).Msg("Create destination dataset config")
+
+		// Record collected items, continuing numbering of resumed collection
+		if c.manifest, err = OpenManifest(c.fs, c.conf.Dest, c.conf.Resume || c.conf.Append); err != nil {
+			log.Fatal().Err(err).Msg("Failed open manifest")
+			return nil, err
+		}
+		defer c.manifest.Close()
+		if err := c.removePending(); err != nil {
+			log.Fatal().Err(err).Msg("Failed remove items of interrupted collection")
+			return nil, err
+		}
+		for cat, incr := range c.increments {
+			c.increments[cat] = utils.NewIncrement(max(incr.Current(), c.manifest.LastId(cat)))
+		}
+		if c.conf.Resume || c.conf.Append {
+			log.Info().Int("items", c.manifest.Len()).Msg("Resume collection from manifest")
+		}
 	}
 
-	// Create data.yaml on root destination folder
-	if err := c.CreateConfig(); err != nil {
-		log.Fatal().Err(err).Msg("Failed create dataset config!")
-		return nil, err
+	// Count classes of items already collected against caps
+	if c.conf.Balance.Enabled {
+		var collected []ManifestEntry
+		if c.manifest != nil {
+			collected = c.manifest.Entries()
+		}
+		c.balance = newBalancer(c.conf.Balance, collected...)
 	}
-	log.Info().Str("dest", c.conf.Dest).Msg("Create destination data.yaml")
 
-	for i := range c.conf.Sources {
-		if err := c.conf.Sources[i].LoadDatasetConfig(c.fs); err != nil {
-			log.Fatal().Err(err).Str("Src", c.conf.Sources[i].Src).Msg("Dataset config can't loaded")
-			continue
+	// Count images of every source already collected
+	if c.manifest != nil {
+		for _, e := range c.manifest.Entries() {
+			c.attribution.contributed(e.Source)
+		}
+	}
+
+	// List items of every source up front, so items can be selected across sources
+	c.sources = slices.Clone(c.conf.Sources)
+	sources := make([][]*DatasetItem, len(c.sources))
+	for i := range c.sources {
+		key := sourceKey(c.sources[i])
+
+		// Download url source into cache, collected as local archive
+		if s := c.sources[i]; s.URL != "" && s.Src == "" {
+			if c.sources[i].Src, err = Download(c.fs, s.URL, s.SHA256, c.conf.Download); err != nil {
+				log.Fatal().Err(err).Str("URL", s.URL).Msg("Failed download source archive")
+				continue
+			}
+		}
+
+		// Collect archive source as directory, mounted on its archive path
+		if utils.IsArchive(c.sources[i].Src) {
+			if err := c.mountArchive(&c.sources[i]); err != nil {
+				log.Fatal().Err(err).Str("Src", c.sources[i].Src).Msg("Failed open source archive")
+				continue
+			}
 		}
-		log.Info().Str("source", c.conf.Sources[i].Src).Msg("Load dataset config successfully")
 
-		log.Info().Str("source", c.conf.Sources[i].Src).Msg("Start collecting dataset from source")
-		_, err := c.Collect(c.conf.Sources[i])
+		reader, err := NewSourceReader(c.sources[i].Format)
 		if err != nil {
-			log.Fatal().Err(err).Str("Src", c.conf.Sources[i].Src).Msg("Failed collect from source")
+			log.Fatal().Err(err).Str("Src", c.sources[i].Src).Msg("Unknown source format")
+			continue
+		}
+
+		if err := reader.LoadDatasetConfig(c.fs, &c.sources[i]); err != nil {
+			log.Fatal().Err(err).Str("Src", c.sources[i].Src).Msg("Dataset config can't loaded")
+			continue
+		}
+		log.Info().Str("source", c.sources[i].Src).Msg("Load dataset config successfully")
+		c.attribution.provenance(key, c.sources[i].DatasetConfig)
+
+		if sources[i], err = reader.Items(c.fs, c.sources[i], c.conf.Dest); err != nil {
+			log.Fatal().Err(err).Str("Src", c.sources[i].Src).Msg("Failed list items from source")
 			continue
 		}
+		for _, item := range sources[i] {
+			item.Source = key
+		}
+
+		if c.sources[i].LabelType == "" {
+			c.sources[i].LabelType = c.detectLabelType(sources[i])
+			log.Info().Str("source", c.sources[i].Src).Str("type", c.sources[i].LabelType).Msg("Detect label type")
+		}
+	}
+
+	if types := c.sourceLabelTypes(); len(types) > 1 && !c.detectOnly() {
+		log.Warn().Strs("types", types).Msg("Sources mix label types, set label_type to detect to convert them into boxes")
+	}
+
+	// Drop duplicated images, re-split and resolve leakage across every source
+	if c.conf.Dedup.Enabled || c.conf.Split.Enabled || c.conf.Leakage.Enabled {
+		sources = c.screenItems(sources)
+	}
+
+	for i, items := range sources {
+		log.Info().Str("source", c.sources[i].Src).Msg("Start collecting dataset from source")
+		c.collectDataset(c.sources[i], items)
+	}
+
+	c.wait()
+
+	// Collect copies of oversampled classes, once every source item is counted
+	if c.balance != nil {
+		c.collectCopies(c.balance.copies())
+		c.wait()
+	}
+
+	for cat, sum := range c.summary {
+		sum.Show(cat)
+	}
+
+	if c.conf.DryRun {
+		c.plan.Sources = c.attribution.Sources()
+		return summary, nil
+	}
+
+	// Flush annotations kept by output writer
+	if err := c.output.Close(c.fs); err != nil {
+		log.Error().Err(err).Msg("Failed write destination annotations")
+		return nil, err
+	}
+
+	// Credit every source with its license
+	if err := SaveAttribution(c.fs, c.attribution.Sources(), c.conf.Dest); err != nil {
+		log.Error().Err(err).Msg("Failed write attribution")
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// mountArchive mounts archive of source on its archive path, setting source to dataset root of archive
+func (c *Collector) mountArchive(src *config.Source) error {
+	archive, err := Uncompress(c.fs, src.Src, c.conf.Archive)
+	if err != nil {
+		return err
+	}
+	c.fs = mount(c.fs, src.Src, archive)
+	src.Src = path.Join(src.Src, archiveRoot(archive))
+
+	log.Info().Str("src", src.Src).Msg("Mount source archive")
+	return nil
+}
+
+// unmountArchives closes every mounted source archive, serving base filesystem again
+func (c *Collector) unmountArchives() {
+	m, ok := c.fs.(*mountFs)
+	if !ok {
+		return
 	}
+	if err := m.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed close source archives")
+	}
+	c.fs = m.Fs
+}
 
-	for {
-		if c.pool.Running() == 0 {
-			for cat, sum := range c.summary {
-				sum.Show(cat)
+// labelSamples is count of source items read to detect its label type
+const labelSamples = 20
+
+// detectLabelType detects label type from labels of the first items of source
+func (c Collector) detectLabelType(items []*DatasetItem) string {
+	var labels [][]byte
+	for _, item := range items[:min(len(items), labelSamples)] {
+		data := item.Label.Data
+		if data == nil {
+			var err error
+			if data, err = afero.ReadFile(c.fs, item.Label.SrcPath); err != nil {
+				continue
 			}
-			break
 		}
+		labels = append(labels, data)
 	}
+	return DetectLabelType(labels...)
+}
 
-	return summary, nil
+// sourceLabelTypes returns distinct label types of every source, sorted
+func (c Collector) sourceLabelTypes() []string {
+	found := make(map[string]bool)
+	for _, s := range c.sources {
+		if s.LabelType != "" {
+			found[s.LabelType] = true
+		}
+	}
+
+	types := make([]string, 0, len(found))
+	for t := range found {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
 }
 
-// CreateConfig generates a new dataset configuration using the collector's configured classes
-// and saves it to the destination directory using the collector's filesystem.
+// detectOnly reports whether destination holds boxes only
+func (c Collector) detectOnly() bool {
+	switch c.conf.OutputFormat {
+	case "", config.FormatYOLO:
+		return c.conf.LabelType == config.LabelTypeDetect
+	}
+	return true
+}
+
+// CreateConfig generates a new dataset configuration using the collector's configured classes,
+// unless already merged with existing destination classes on append,
+// and saves it to the destination directory through the output writer.
 func (c *Collector) CreateConfig() error {
-	c.datasetConf = config.NewDataset(c.conf.Classes...)
+	if c.datasetConf == nil {
+		c.datasetConf = config.NewDataset(c.conf.Classes...)
+	}
+	if c.conf.NamesForm != "" {
+		c.datasetConf.NamesForm = c.conf.NamesForm
+	}
 
-	return config.SaveDataset(c.fs, *c.datasetConf, c.conf.Dest)
+	return c.output.CreateConfig(c.fs, *c.datasetConf, c.conf.Dest)
 }
 
 // Collect collecting images and label folder and renaming to destination
 func (c Collector) Collect(src config.Source) (summary CategorizedSummary, err error) {
-	entry, err := afero.ReadDir(c.fs, src.Src)
+	reader, err := NewSourceReader(src.Format)
 	if err != nil {
 		return nil, err
 	}
 
-	// Lookup for each folder on root source directory
-	// Ensures the datasets folder on cross-named folder category
-	for _, e := range entry {
-		if !e.IsDir() {
-			continue
-		}
-
-		dir := path.Join(src.Src, e.Name())
-
-		cat := utils.FindCategory(e.Name())
-		if cat == nil {
-			continue
-		}
-
-		log.Info().Any("name", e.Name()).Str("Path", dir).Msg("Collecting dataset on folder.")
-		if err := c.collectDataset(src, *cat, dir); err != nil {
-			log.Warn().Err(err).Str("dir", dir).Msg("Failed collect from folder")
-		}
+	items, err := reader.Items(c.fs, src, c.conf.Dest)
+	if err != nil {
+		return nil, err
 	}
 
+	c.collectDataset(src, items)
+
 	return c.summary, nil
 }
 
 func (c Collector) collectItem(item *DatasetItem, src config.Source) (classes CollectClasses, err error) {
-	// Read label file
-	item.Label.Data, err = afero.ReadFile(c.fs, item.Label.SrcPath)
-	if err != nil {
-		return classes, err
+	// Read label file, unless already converted by the source reader
+	if item.Label.Data == nil {
+		item.Label.Data, err = afero.ReadFile(c.fs, item.Label.SrcPath)
+		if err != nil {
+			return classes, err
+		}
+	}
+
+	// Validate label lines, repairing or dropping invalid objects by mode
+	if c.conf.Labels.Enabled {
+		check, err := ValidateLabel(item.Label.Data, c.conf.Labels.Mode, src.LabelType)
+		for _, p := range check.Problems {
+			log.Warn().
+				Str("src", utils.RightWrap(item.Label.SrcPath, 75)).
+				Int("line", p.Line).
+				Str("action", p.Action).
+				Msg(p.Msg)
+		}
+		if err != nil {
+			return classes, err
+		}
+		c.summary[item.Cat].validated(check)
+		item.Label.Data = check.Data
 	}
 
 	// Sync class index
@@ -268,45 +620,166 @@ func (c Collector) collectItem(item *DatasetItem, src config.Source) (classes Co
 		return classes, err
 	}
 
-	// Read image file
-	item.Image.Data, err = afero.ReadFile(c.fs, item.Image.SrcPath)
-	if err != nil {
+	// Convert polygons and oriented boxes for detection only destination
+	if c.detectOnly() && src.LabelType != config.LabelTypeDetect {
+		item.Label.Data = ToDetectLabel(item.Label.Data)
+	}
+
+	// Hash image file streamed from source, image is never held in memory unless transformed.
+	// Dry-run only ensures it exists, unless hash is needed to find items already collected.
+	var size int64
+	if c.conf.DryRun && c.manifest == nil {
+		info, err := c.fs.Stat(item.Image.SrcPath)
+		if err != nil {
+			return classes, err
+		}
+		size = info.Size()
+	} else if item.Hash, size, err = hashFile(c.fs, item.Image.SrcPath); err != nil {
 		return classes, err
 	}
 
-	if len(item.Label.Data) == 0 || len(item.Image.Data) == 0 {
+	if len(item.Label.Data) == 0 || size == 0 {
 		return classes, errors.New("Label or image file is empty")
 	}
 
-	// Set destination objects
-	item.SetNewFilename(c.GetIncrement(item.Cat))
+	// Reject corrupt image, or image not matching its extension
+	if err := c.verifyImage(item); err != nil {
+		return classes, err
+	}
 
-	if err := c.writeToDest(item); err != nil {
+	// Skip item exceeding class caps, unless written by resumed collection.
+	// Label is sampled for oversampling before transform, as copies are transformed again.
+	if c.balance != nil {
+		_, collected := c.collected(item)
+		if !c.balance.reserve(item, classes, collected) {
+			return classes, errCapped
+		}
+	}
+
+	// Re-encode image into configured format and size, otherwise link it to source
+	if err := c.prepareImage(item); err != nil {
 		return classes, err
 	}
 
-	return classes, nil
+	return classes, c.placeItem(item, classes)
 }
 
-func (c Collector) collectDataset(src config.Source, cat utils.Category, dir string) (err error) {
-	defer func() {
-		if err != nil {
-			log.Warn().Err(err).Send()
+// prepareImage transforms image of item when configured, linking image not transformed by link mode
+func (c Collector) prepareImage(item *DatasetItem) error {
+	if c.conf.Transform.Enabled {
+		changed, err := c.transformImage(item)
+		if err != nil || changed {
+			return err
 		}
-	}()
+	}
+	// Archive entries can't be linked, so they are copied
+	if m, ok := c.fs.(*mountFs); ok && m.mounted(item.Image.SrcPath) {
+		return nil
+	}
+	item.Image.Link = c.conf.LinkMode
+	return nil
+}
 
-	// read image filename as key
-	images, err := afero.ReadDir(c.fs, path.Join(dir, "images"))
-	if err != nil {
+// collected returns manifest entry of item written by resumed collection on the same category
+func (c Collector) collected(item *DatasetItem) (ManifestEntry, bool) {
+	if c.manifest == nil {
+		return ManifestEntry{}, false
+	}
+	e, ok := c.manifest.Find(item.Image.SrcPath, item.Hash, item.Copy)
+	return e, ok && e.Category == item.Cat
+}
+
+// placeItem sets destination of item with a new id and writes it, or adds it to plan on dry-run.
+// Item written by resumed collection keeps its id, returning errCollected.
+func (c Collector) placeItem(item *DatasetItem, classes CollectClasses) error {
+	// Keep item written by resumed collection
+	if e, ok := c.collected(item); ok {
+		item.SetNewFilename(e.Id)
+		c.output.SetDestPaths(item)
+		if c.conf.DryRun {
+			return errCollected
+		}
+		if err := c.output.Index(item); err != nil {
+			return err
+		}
+		return errCollected
+	}
+
+	// Set destination objects
+	item.SetNewFilename(c.GetIncrement(item.Cat))
+	c.output.SetDestPaths(item)
+
+	if c.conf.DryRun {
+		c.plan.add(item, classes)
+		return nil
+	}
+
+	if err := c.manifest.Begin(item); err != nil {
 		return err
 	}
+	if err := c.writeToDest(item); err != nil {
+		return err
+	}
+
+	return c.manifest.Add(item, classes)
+}
+
+// collectCopies collects oversampled copies of items, labels already synced with their classes
+func (c Collector) collectCopies(items []*DatasetItem, classes []CollectClasses) {
+	for i, item := range items {
+		c.pool.Submit(func() {
+			err := c.prepareImage(item)
+			if err == nil {
+				err = c.placeItem(item, classes[i])
+			}
+			if errors.Is(err, errCollected) {
+				c.summary[item.Cat].skipped(classes[i])
+				return
+			}
+			if err != nil {
+				c.summary[item.Cat].failed()
+				log.Warn().Err(err).Str("src", utils.RightWrap(item.Image.SrcPath, 75)).Msg("Failed collecting copy.")
+				return
+			}
+
+			c.summary[item.Cat].oversampled(classes[i])
+			c.attribution.contributed(item.Source)
+			log.Info().
+				Int("_id", item.Id).
+				Int("copy", item.Copy).
+				Str("src", utils.RightWrap(item.Image.SrcFilename, 25)).
+				Msg("Dataset copy collected.")
+		})
+	}
+}
+
+// wait blocks until every submitted item is collected
+func (c Collector) wait() {
+	for c.pool.Running() > 0 {
+	}
+}
 
-	for _, f := range images {
+func (c Collector) collectDataset(src config.Source, items []*DatasetItem) {
+	for _, item := range items {
 		c.pool.Submit(func() {
-			item := CreateDatasetItem(dir, c.conf.Dest, f.Name(), cat)
 			cls, err := c.collectItem(item, src)
+			if errors.Is(err, errCollected) {
+				c.summary[item.Cat].skipped(cls)
+				log.Debug().Int("_id", item.Id).Str("src", utils.RightWrap(item.Image.SrcPath, 75)).Msg("Dataset already collected.")
+				return
+			}
+			if errors.Is(err, errCapped) {
+				c.summary[item.Cat].capped()
+				log.Debug().Str("src", utils.RightWrap(item.Image.SrcPath, 75)).Any("xClass", cls).Msg("Dataset exceeds class cap.")
+				return
+			}
 			if err != nil {
-				c.summary[cat].failed()
+				var imgErr ImageError
+				if errors.As(err, &imgErr) {
+					c.summary[item.Cat].failed(imgErr.Reason)
+				} else {
+					c.summary[item.Cat].failed()
+				}
 				log.Warn().
 					Err(err).
 					Str("src", utils.RightWrap(item.Image.SrcPath, 75)).
@@ -315,7 +788,8 @@ func (c Collector) collectDataset(src config.Source, cat utils.Category, dir str
 			}
 
 			// Update summary
-			c.summary[cat].success(cls)
+			c.summary[item.Cat].success(cls)
+			c.attribution.contributed(item.Source)
 			log.Info().
 				Int("_id", item.Id).
 				Str("src", utils.RightWrap(item.Image.SrcFilename, 25)).
@@ -324,38 +798,10 @@ func (c Collector) collectDataset(src config.Source, cat utils.Category, dir str
 				Msg("Dataset collected.")
 		})
 	}
-
-	return nil
-}
-
-func (c Collector) write(path string, data []byte) error {
-	f, err := c.fs.Create(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	_, err = f.Write(data)
-	return err
 }
 
-func (c Collector) writeToDest(item *DatasetItem) (err error) {
-	// Delete file on error
-	defer func() {
-		if err != nil {
-			c.fs.Remove(item.Image.DstPath)
-			c.fs.Remove(item.Label.DstPath)
-		}
-	}()
-
-	if err = c.write(item.Image.DstPath, item.Image.Data); err != nil {
-		return err
-	}
-	if err = c.write(item.Label.DstPath, item.Label.Data); err != nil {
-		return err
-	}
-
-	return nil
+func (c Collector) writeToDest(item *DatasetItem) error {
+	return c.output.Write(c.fs, item)
 }
 
 func (c Collector) syncClasses(object string, src config.Source) (res string, classes string, err error) {
@@ -409,25 +855,8 @@ func (c Collector) SyncClasses(body []byte, src config.Source) ([]byte, map[stri
 	return []byte(strings.Join(newObjects, "\n")), classes, nil
 }
 
-// CreateDestFolder creates the destination directory structure for training, testing, and validation datasets
-// with separate subdirectories for images and labels. Returns an error if directory creation fails.
+// CreateDestFolder creates the destination directory structure of the output format.
+// Returns an error if directory creation fails.
 func (c Collector) CreateDestFolder() error {
-	var err error
-
-	// Create dest root directory
-	err = c.fs.MkdirAll(c.conf.Dest, os.ModePerm)
-
-	// Create training dataset directory on root directory
-	err = c.fs.MkdirAll(path.Join(c.conf.Dest, "train", "images"), os.ModePerm)
-	err = c.fs.MkdirAll(path.Join(c.conf.Dest, "train", "labels"), os.ModePerm)
-
-	// Create testing dataset directory on root directory
-	err = c.fs.MkdirAll(path.Join(c.conf.Dest, "test", "images"), os.ModePerm)
-	err = c.fs.MkdirAll(path.Join(c.conf.Dest, "test", "labels"), os.ModePerm)
-
-	// Create validation dataset directory on root directory
-	err = c.fs.MkdirAll(path.Join(c.conf.Dest, "valid", "images"), os.ModePerm)
-	err = c.fs.MkdirAll(path.Join(c.conf.Dest, "valid", "labels"), os.ModePerm)
-
-	return err
+	return c.output.CreateDestFolder(c.fs, c.conf.Dest)
 }
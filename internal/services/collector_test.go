@@ -0,0 +1,235 @@
+package services
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/goccy/go-json"
+	"github.com/spf13/afero"
+)
+
+// testYOLOSource writes a YOLO source with images per category, each labelled with given lines
+func testYOLOSource(t testing.TB, fs afero.Fs, src string, images map[string][]string) config.Source {
+	afero.WriteFile(fs, path.Join(src, "data.yaml"), []byte(`names: ["bus", "car", "van"]`), 0644)
+	for cat, names := range images {
+		for _, n := range names {
+			afero.WriteFile(fs, path.Join(src, cat, "images", n+".png"), testImage(t, 20, 10), 0644)
+			afero.WriteFile(fs, path.Join(src, cat, "labels", n+".txt"), []byte("1 0.5 0.5 0.2 0.2\n2 0.3 0.3 0.1 0.1"), 0644)
+		}
+	}
+
+	s := config.Source{Src: src}
+	s.ClassSync.UnmarshalJSON([]byte(`{"car": ["car", "van"], "bus": ["bus"]}`))
+	return s
+}
+
+func testCollector(t testing.TB, fs afero.Fs, conf *config.Config) *Collector {
+	if conf.Workers == 0 {
+		conf.Workers = 5
+	}
+	if conf.Classes == nil {
+		conf.Classes = []string{"bus", "car"}
+	}
+	c, err := NewCollector(conf, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCollectDryRun(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	src := testYOLOSource(t, fs, "src", map[string][]string{"train": {"a", "b"}, "valid": {"c"}})
+
+	c := testCollector(t, fs, &config.Config{Dest: "dst", DryRun: true, Sources: []config.Source{src}})
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := afero.Exists(fs, "dst"); ok {
+		t.Error("dry-run created destination")
+	}
+
+	plan := c.Plan()
+	if len(plan.Items) != 3 {
+		t.Fatalf("got %d planned items, want 3", len(plan.Items))
+	}
+	if got := plan.Summary["train"].Classes["car"]; got != 4 {
+		t.Errorf("train car count = %d, want 4", got)
+	}
+	plan.sort()
+	if plan.Items[0].Dst != "dst/train/images/train_1.png" {
+		t.Errorf("first planned dst = %q", plan.Items[0].Dst)
+	}
+}
+
+func TestCollectDryRunCollected(t *testing.T) {
+	for _, mode := range []string{"append", "resume"} {
+		t.Run(mode, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			src := testYOLOSource(t, fs, "src", map[string][]string{"train": {"a", "b"}})
+			conf := &config.Config{Dest: "dst", Sources: []config.Source{src}}
+			if _, err := testCollector(t, fs, conf).CollectAll(); err != nil {
+				t.Fatal(err)
+			}
+			manifest, _ := afero.ReadFile(fs, "dst/"+ManifestFile)
+
+			// Plan maps only the new image, numbered after items already collected
+			testYOLOSource(t, fs, "src", map[string][]string{"train": {"c"}})
+			conf.DryRun, conf.Append, conf.Resume = true, mode == "append", mode == "resume"
+			c := testCollector(t, fs, conf)
+			if _, err := c.CollectAll(); err != nil {
+				t.Fatal(err)
+			}
+
+			plan := c.Plan()
+			if len(plan.Items) != 1 || plan.Items[0].Dst != "dst/train/images/train_3.png" {
+				t.Errorf("got planned items %+v, want only train_3", plan.Items)
+			}
+			if sum := c.summary["train"]; sum.Skipped != 2 {
+				t.Errorf("got %d skipped, want 2", sum.Skipped)
+			}
+			if after, _ := afero.ReadFile(fs, "dst/"+ManifestFile); string(after) != string(manifest) {
+				t.Error("dry-run changed manifest")
+			}
+		})
+	}
+}
+
+func TestCollectResume(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	src := testYOLOSource(t, fs, "src", map[string][]string{"train": {"a", "b", "c"}})
+
+	conf := &config.Config{Dest: "dst", Sources: []config.Source{src}}
+	if _, err := testCollector(t, fs, conf).CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate process died while writing the first item, leaving it truncated and only pending,
+	// after later items were recorded, then a new image arrives
+	b, _ := afero.ReadFile(fs, "dst/"+ManifestFile)
+	var (
+		manifest []string
+		first    ManifestEntry
+	)
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		var e ManifestEntry
+		if json.Unmarshal([]byte(line), &e) != nil || e.Pending {
+			continue
+		}
+		if e.Id == 1 {
+			first = e
+			e.Pending = true
+			p, _ := json.Marshal(e)
+			line = string(p) + "\n"
+		}
+		manifest = append(manifest, line)
+	}
+	afero.WriteFile(fs, "dst/"+ManifestFile, []byte(strings.Join(manifest, "")), 0644)
+	afero.WriteFile(fs, first.Dst, []byte("truncated"), 0644)
+	testYOLOSource(t, fs, "src", map[string][]string{"train": {"d"}})
+
+	conf.Resume = true
+	c := testCollector(t, fs, conf)
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum := c.summary["train"]; sum.Skipped != 2 || sum.Success != 2 {
+		t.Errorf("got skipped %d success %d, want 2 and 2", sum.Skipped, sum.Success)
+	}
+	if n := c.manifest.Len(); n != 4 {
+		t.Errorf("manifest has %d entries, want 4", n)
+	}
+
+	// Interrupted item is collected again, without leaving its truncated file behind
+	recorded := make(map[string]bool)
+	for _, e := range c.manifest.Entries() {
+		recorded[e.Dst] = true
+	}
+	images, _ := afero.ReadDir(fs, "dst/train/images")
+	for _, f := range images {
+		if !recorded["dst/train/images/"+f.Name()] {
+			t.Errorf("%s not recorded on manifest", f.Name())
+		}
+	}
+	if len(images) != 4 {
+		t.Errorf("got %d images, want 4", len(images))
+	}
+}
+
+func TestCollectAppend(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	first := testYOLOSource(t, fs, "first", map[string][]string{"train": {"a", "b"}})
+	if _, err := testCollector(t, fs, &config.Config{Dest: "dst", Sources: []config.Source{first}}).CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := afero.ReadFile(fs, "dst/train/labels/train_1.txt")
+
+	second := testYOLOSource(t, fs, "second", map[string][]string{"train": {"c"}})
+	second.ClassSync.UnmarshalJSON([]byte(`{"truck": ["van"], "car": ["car"]}`))
+	conf := &config.Config{Dest: "dst", Append: true, Classes: []string{"car", "truck"}, Sources: []config.Source{second}}
+	if _, err := testCollector(t, fs, conf).CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	dataset, err := config.LoadDataset(fs, "dst/data.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(dataset.Names, ","); got != "bus,car,truck" {
+		t.Errorf("destination classes = %s, want bus,car,truck", got)
+	}
+	if after, _ := afero.ReadFile(fs, "dst/train/labels/train_1.txt"); string(after) != string(before) {
+		t.Error("append overwrote existing item")
+	}
+	label, err := afero.ReadFile(fs, "dst/train/labels/train_3.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "1 0.5 0.5 0.2 0.2\n2 0.3 0.3 0.1 0.1"; string(label) != want {
+		t.Errorf("appended label = %q, want %q", label, want)
+	}
+}
+
+func TestCollectAppendFormats(t *testing.T) {
+	for _, format := range []string{config.FormatCOCO, config.FormatVOC} {
+		t.Run(format, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			src := testYOLOSource(t, fs, "src", map[string][]string{"train": {"a", "b"}})
+			conf := &config.Config{Dest: "dst", OutputFormat: format, Sources: []config.Source{src}}
+			if _, err := testCollector(t, fs, conf).CollectAll(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Items already collected are kept once next to the appended one
+			testYOLOSource(t, fs, "src", map[string][]string{"train": {"c"}})
+			conf.Append = true
+			if _, err := testCollector(t, fs, conf).CollectAll(); err != nil {
+				t.Fatal(err)
+			}
+
+			switch format {
+			case config.FormatCOCO:
+				coco, err := LoadCoco(fs, "dst/train/"+CocoAnnotationsFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var ids []int
+				for _, img := range coco.Images {
+					ids = append(ids, img.Id)
+				}
+				if len(ids) != 3 || len(coco.Annotations) != 6 {
+					t.Errorf("got image ids %v with %d annotations, want 3 images with 6", ids, len(coco.Annotations))
+				}
+			case config.FormatVOC:
+				b, _ := afero.ReadFile(fs, "dst/"+VocImageSetsDir+"/train.txt")
+				if got := strings.Fields(string(b)); strings.Join(got, ",") != "train_1,train_2,train_3" {
+					t.Errorf("got train set %v, want train_1 to train_3 once", got)
+				}
+			}
+		})
+	}
+}
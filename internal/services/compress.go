@@ -1,5 +1,369 @@
 package services
 
-func Uncompress(filename string) {
-	
-}
\ No newline at end of file
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+	"github.com/spf13/afero/zipfs"
+)
+
+// Default archive limits
+const (
+	DefaultArchiveMaxSize  = 1 << 30
+	DefaultArchiveMaxFiles = 1_000_000
+)
+
+// errArchiveLimit reports archive exceeding configured limits
+var errArchiveLimit = errors.New("archive exceeds limit")
+
+// ArchiveFs is read-only filesystem of an opened archive, Close releases archive file
+type ArchiveFs interface {
+	afero.Fs
+	io.Closer
+}
+
+// Uncompress opens zip or tar archive filename on fs as read-only filesystem rooted at archive root.
+// Zip and plain tar are not extracted, their entries are read on demand from archive file, by central
+// directory of zip and by offsets indexed on open for tar. Tar.gz has no random access, so it is extracted:
+// file contents are decompressed into a single temporary file on temp directory of fs, taking disk space
+// up to uncompressed size (at most limits.MaxSize) until Close removes it. Archives with entries escaping
+// its root, or exceeding limits, are rejected.
+func Uncompress(fs afero.Fs, filename string, limits config.Archive) (ArchiveFs, error) {
+	if limits.MaxSize <= 0 {
+		limits.MaxSize = DefaultArchiveMaxSize
+	}
+	if limits.MaxFiles <= 0 {
+		limits.MaxFiles = DefaultArchiveMaxFiles
+	}
+
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.ToLower(filename)
+	if strings.HasSuffix(name, ".zip") {
+		// Zip entries are read from file as needed, so it is kept open until Close
+		return uncompressZip(f, limits)
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+		return spoolTar(fs, f, limits)
+	}
+
+	// Tar reader reads headers straight from file, so file offset is where entry content starts
+	t, err := indexTar(f, limits, func(*tar.Reader) (int64, error) {
+		return f.Seek(0, io.SeekCurrent)
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.fs, t.name = fs, filename
+	return t, nil
+}
+
+// archivePath returns cleaned entry path rooted on archive, rejecting entry escaping archive root
+func archivePath(name string) (string, error) {
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("archive entry %q escapes archive root", name)
+	}
+	return path.Join("/", name), nil
+}
+
+func uncompressZip(f afero.File, limits config.Archive) (ArchiveFs, error) {
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if len(r.File) > limits.MaxFiles {
+		f.Close()
+		return nil, fmt.Errorf("%w: %d entries, max %d", errArchiveLimit, len(r.File), limits.MaxFiles)
+	}
+
+	var (
+		size int64
+		dirs = make(map[string]bool)
+	)
+	for _, file := range r.File {
+		name, err := archivePath(file.Name)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if size += int64(file.UncompressedSize64); size > limits.MaxSize {
+			f.Close()
+			return nil, fmt.Errorf("%w: uncompressed size over %d bytes", errArchiveLimit, limits.MaxSize)
+		}
+
+		if file.FileInfo().IsDir() {
+			dirs[name] = true
+		}
+	}
+
+	// Zip may omit directory entries, which are needed to list their files
+	var implied []string
+	for _, file := range r.File {
+		name, _ := archivePath(file.Name)
+		for dir := path.Dir(name); dir != "/" && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+			implied = append(implied, dir)
+		}
+	}
+	sort.Strings(implied)
+	for _, dir := range implied {
+		r.File = append(r.File, &zip.File{FileHeader: zip.FileHeader{Name: strings.TrimPrefix(dir, "/") + "/"}})
+	}
+
+	return zipFs{Fs: zipfs.New(r), file: f}, nil
+}
+
+// zipFs serves zip entries read from its archive file
+type zipFs struct {
+	afero.Fs
+	file afero.File
+}
+
+func (z zipFs) Close() error { return z.file.Close() }
+
+// indexTar indexes entries of tar read from r. Place is called on every regular file to give offset
+// of its content on file served by tarFs, reading content from tar reader when needed.
+func indexTar(r io.Reader, limits config.Archive, place func(tr *tar.Reader) (int64, error)) (*tarFs, error) {
+	var (
+		t     = newTarFs()
+		tr    = tar.NewReader(r)
+		size  int64
+		count int
+	)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if count++; count > limits.MaxFiles {
+			return nil, fmt.Errorf("%w: over %d entries", errArchiveLimit, limits.MaxFiles)
+		}
+		name, err := archivePath(h.Name)
+		if err != nil {
+			return nil, err
+		}
+		if name == "/" {
+			continue
+		}
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			t.entries[name] = tarEntry{info: h.FileInfo()}
+		case tar.TypeReg:
+			// Sparse file content is not stored as one section of archive
+			if sparseTar(h) {
+				continue
+			}
+			if size += h.Size; size > limits.MaxSize {
+				return nil, fmt.Errorf("%w: uncompressed size over %d bytes", errArchiveLimit, limits.MaxSize)
+			}
+			offset, err := place(tr)
+			if err != nil {
+				return nil, err
+			}
+			t.entries[name] = tarEntry{info: h.FileInfo(), offset: offset}
+		}
+		// Links and special files are skipped, as they may point outside archive
+	}
+
+	t.index()
+	return t, nil
+}
+
+// sparseTar reports whether tar entry is a PAX sparse file
+func sparseTar(h *tar.Header) bool {
+	for k := range h.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// spoolTar extracts file contents of gzip tar read from r into a temporary file of fs, as uncompressed
+// as they are, served as tarFs removing the file on Close
+func spoolTar(fs afero.Fs, r io.Reader, limits config.Archive) (*tarFs, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	spool, err := afero.TempFile(fs, "", "dataset_collector-*.tar")
+	if err != nil {
+		return nil, err
+	}
+
+	var written int64
+	t, err := indexTar(gz, limits, func(tr *tar.Reader) (int64, error) {
+		n, err := copyBuffer(spool, tr)
+		written += n
+		return written - n, err
+	})
+	if cerr := spool.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fs.Remove(spool.Name())
+		return nil, err
+	}
+
+	t.fs, t.name, t.spooled = fs, spool.Name(), true
+	return t, nil
+}
+
+// archiveRoot returns dataset root of archive, descending into a single top-level folder
+// as archives often wrap the dataset in a folder
+func archiveRoot(fs afero.Fs) string {
+	root := "/"
+	for {
+		entry, err := afero.ReadDir(fs, root)
+		if err != nil || len(entry) != 1 || !entry[0].IsDir() {
+			return root
+		}
+		root = path.Join(root, entry[0].Name())
+	}
+}
+
+// mountFs serves archive filesystems on their archive path, every other path is served by base
+type mountFs struct {
+	afero.Fs
+	mounts map[string]afero.Fs
+}
+
+// mount serves archive on archive path of fs, mounting onto fs already mounting other archives
+func mount(fs afero.Fs, archive string, archiveFs afero.Fs) afero.Fs {
+	m, ok := fs.(*mountFs)
+	if !ok {
+		m = &mountFs{Fs: fs, mounts: make(map[string]afero.Fs)}
+	}
+	if c, ok := m.mounts[path.Clean(archive)].(io.Closer); ok {
+		c.Close()
+	}
+	m.mounts[path.Clean(archive)] = archiveFs
+	return m
+}
+
+// Close unmounts every archive, closing archives holding their file
+func (m *mountFs) Close() error {
+	var errs []error
+	for archive, fs := range m.mounts {
+		if c, ok := fs.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+		delete(m.mounts, archive)
+	}
+	return errors.Join(errs...)
+}
+
+// resolve returns filesystem serving name with name on it
+func (m *mountFs) resolve(name string) (afero.Fs, string) {
+	name = path.Clean(name)
+	for archive, fs := range m.mounts {
+		if name == archive {
+			return fs, "/"
+		}
+		if strings.HasPrefix(name, archive+"/") {
+			return fs, strings.TrimPrefix(name, archive)
+		}
+	}
+	return m.Fs, name
+}
+
+// mounted reports whether name is served by a mounted archive
+func (m *mountFs) mounted(name string) bool {
+	fs, _ := m.resolve(name)
+	return fs != m.Fs
+}
+
+func (m *mountFs) Create(name string) (afero.File, error) {
+	fs, name := m.resolve(name)
+	return fs.Create(name)
+}
+
+func (m *mountFs) Mkdir(name string, perm os.FileMode) error {
+	fs, name := m.resolve(name)
+	return fs.Mkdir(name, perm)
+}
+
+func (m *mountFs) MkdirAll(name string, perm os.FileMode) error {
+	fs, name := m.resolve(name)
+	return fs.MkdirAll(name, perm)
+}
+
+func (m *mountFs) Open(name string) (afero.File, error) {
+	fs, name := m.resolve(name)
+	return fs.Open(name)
+}
+
+func (m *mountFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	fs, name := m.resolve(name)
+	return fs.OpenFile(name, flag, perm)
+}
+
+func (m *mountFs) Remove(name string) error {
+	fs, name := m.resolve(name)
+	return fs.Remove(name)
+}
+
+func (m *mountFs) RemoveAll(name string) error {
+	fs, name := m.resolve(name)
+	return fs.RemoveAll(name)
+}
+
+func (m *mountFs) Rename(oldname, newname string) error {
+	if m.mounted(oldname) || m.mounted(newname) {
+		return syscall.EPERM
+	}
+	return m.Fs.Rename(oldname, newname)
+}
+
+func (m *mountFs) Stat(name string) (os.FileInfo, error) {
+	fs, name := m.resolve(name)
+	return fs.Stat(name)
+}
+
+func (m *mountFs) Name() string { return "MountFs" }
+
+func (m *mountFs) Chmod(name string, mode os.FileMode) error {
+	fs, name := m.resolve(name)
+	return fs.Chmod(name, mode)
+}
+
+func (m *mountFs) Chown(name string, uid, gid int) error {
+	fs, name := m.resolve(name)
+	return fs.Chown(name, uid, gid)
+}
+
+func (m *mountFs) Chtimes(name string, atime, mtime time.Time) error {
+	fs, name := m.resolve(name)
+	return fs.Chtimes(name, atime, mtime)
+}
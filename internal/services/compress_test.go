@@ -0,0 +1,158 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+)
+
+// testArchive writes files as tar or tar.gz archive by extension of name, otherwise as zip,
+// without directory entries
+func testArchive(t *testing.T, fs afero.Fs, name string, files map[string][]byte) {
+	names := make([]string, 0, len(files))
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	writeTar := func(w io.Writer) {
+		tw := tar.NewWriter(w)
+		for _, n := range names {
+			tw.WriteHeader(&tar.Header{Name: n, Mode: 0644, Size: int64(len(files[n])), Typeflag: tar.TypeReg})
+			tw.Write(files[n])
+		}
+		tw.Close()
+	}
+
+	var buf bytes.Buffer
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		writeTar(&buf)
+	case strings.HasSuffix(name, ".tar.gz"):
+		gz := gzip.NewWriter(&buf)
+		writeTar(gz)
+		gz.Close()
+	default:
+		zw := zip.NewWriter(&buf)
+		for _, n := range names {
+			w, _ := zw.Create(n)
+			w.Write(files[n])
+		}
+		zw.Close()
+	}
+	if err := afero.WriteFile(fs, name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectArchive(t *testing.T) {
+	var (
+		img   = testImage(t, 20, 10)
+		label = []byte("1 0.5 0.5 0.2 0.2")
+		files = map[string][]byte{
+			"export/data.yaml":          []byte(`names: ["bus", "car", "van"]`),
+			"export/train/images/a.png": img,
+			"export/train/labels/a.txt": label,
+			"export/valid/images/b.png": img,
+			"export/valid/labels/b.txt": label,
+		}
+	)
+
+	for _, name := range []string{"src.zip", "src.tar", "src.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			testArchive(t, fs, name, files)
+
+			src := config.Source{Src: name}
+			src.ClassSync.UnmarshalJSON([]byte(`{"car": ["car"]}`))
+			c := testCollector(t, fs, &config.Config{Dest: "dst", Sources: []config.Source{src}})
+			if _, err := c.CollectAll(); err != nil {
+				t.Fatal(err)
+			}
+
+			if c.summary["train"].Success != 1 || c.summary["valid"].Success != 1 {
+				t.Errorf("got train %d valid %d collected, want 1 and 1", c.summary["train"].Success, c.summary["valid"].Success)
+			}
+			if ok, _ := afero.Exists(fs, "dst/valid/images/valid_1.png"); !ok {
+				t.Error("valid_1.png not found")
+			}
+
+			// Configured source is kept as archive, so the same config is collected again
+			if c.conf.Sources[0].Src != name {
+				t.Errorf("configured src changed to %q", c.conf.Sources[0].Src)
+			}
+			c.conf.Resume = true
+			c = testCollector(t, fs, c.conf)
+			if _, err := c.CollectAll(); err != nil {
+				t.Fatal(err)
+			}
+			if c.summary["train"].Skipped != 1 || c.summary["valid"].Skipped != 1 {
+				t.Errorf("got train %d valid %d skipped, want 1 and 1", c.summary["train"].Skipped, c.summary["valid"].Skipped)
+			}
+		})
+	}
+}
+
+func TestUncompressRejects(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	testArchive(t, fs, "slip.zip", map[string][]byte{"../evil.txt": []byte("x")})
+	testArchive(t, fs, "many.tar.gz", map[string][]byte{"a.txt": nil, "b.txt": nil})
+
+	if _, err := Uncompress(fs, "slip.zip", config.Archive{}); err == nil {
+		t.Error("archive escaping its root is not rejected")
+	}
+	if _, err := Uncompress(fs, "many.tar.gz", config.Archive{MaxFiles: 1}); !errors.Is(err, errArchiveLimit) {
+		t.Errorf("got error %v, want archive limit", err)
+	}
+}
+
+func TestUncompressTar(t *testing.T) {
+	files := map[string][]byte{
+		"export/data.yaml":          []byte(`names: ["bus"]`),
+		"export/train/images/a.png": []byte("image a"),
+		"export/train/images/b.png": []byte("image b"),
+	}
+
+	for _, name := range []string{"src.tar", "src.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			testArchive(t, fs, name, files)
+
+			archive, err := Uncompress(fs, name, config.Archive{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if root := archiveRoot(archive); root != "/export" {
+				t.Errorf("got root %q, want /export", root)
+			}
+			entry, err := afero.ReadDir(archive, "/export/train/images")
+			if err != nil || len(entry) != 2 || entry[0].Name() != "a.png" || entry[1].Name() != "b.png" {
+				t.Errorf("got entries %v (%v), want a.png and b.png", entry, err)
+			}
+			for n, want := range files {
+				if got, err := afero.ReadFile(archive, n); err != nil || !bytes.Equal(got, want) {
+					t.Errorf("%s: got %q (%v), want %q", n, got, err, want)
+				}
+			}
+
+			// Decompressed contents are spooled until archive is closed
+			if err := archive.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if spooled, _ := afero.Glob(fs, path.Join(os.TempDir(), "dataset_collector-*")); len(spooled) != 0 {
+				t.Errorf("spooled files %v kept after close", spooled)
+			}
+		})
+	}
+}
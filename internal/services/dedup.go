@@ -0,0 +1,79 @@
+package services
+
+import (
+	"path"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/goccy/go-json"
+	"github.com/spf13/afero"
+)
+
+// DuplicatesFile is the dropped duplicates report written on destination root
+const DuplicatesFile = "duplicates.json"
+
+// Duplicate reasons
+const (
+	DuplicateExact = "exact"
+	DuplicateNear  = "near"
+)
+
+// Duplicate is a dropped image, duplicating the kept one
+type Duplicate struct {
+	Kept     string `json:"kept"`
+	Dropped  string `json:"dropped"`
+	Reason   string `json:"reason"`
+	Distance int    `json:"distance"`
+}
+
+// dedup finds duplicated images within fingerprinted items, by identical content
+// and optionally by perceptual hash, keeping one copy of each according to policy.
+// Returns the dropped items, with every dropped duplicate.
+func (c Collector) dedup(fps []*fingerprint, conf config.Dedup) (map[*DatasetItem]bool, []Duplicate) {
+	var (
+		dropped    = make(map[*DatasetItem]bool)
+		duplicates []Duplicate
+	)
+	for _, group := range groupFingerprints(fps, conf.Perceptual, conf.Threshold) {
+		// Select kept item of group, groups are ordered by source order
+		k := group[0]
+		if conf.Policy == config.DedupKeepLargest {
+			for _, i := range group {
+				if fps[i].pixels > fps[k].pixels {
+					k = i
+				}
+			}
+		}
+
+		for _, i := range group {
+			if i == k {
+				continue
+			}
+
+			d := Duplicate{
+				Kept:    fps[k].item.Image.SrcPath,
+				Dropped: fps[i].item.Image.SrcPath,
+				Reason:  DuplicateExact,
+			}
+			if fps[i].item.Hash != fps[k].item.Hash {
+				d.Reason = DuplicateNear
+				d.Distance = utils.HammingDistance(fps[i].phash, fps[k].phash)
+			}
+			dropped[fps[i].item] = true
+			duplicates = append(duplicates, d)
+
+			c.summary[fps[i].item.Cat].duplicated()
+		}
+	}
+
+	return dropped, duplicates
+}
+
+// SaveDuplicates writes dropped duplicates report on dest
+func SaveDuplicates(fs afero.Fs, duplicates []Duplicate, dest string) error {
+	b, err := json.MarshalIndent(duplicates, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(fs, path.Join(dest, DuplicatesFile), b)
+}
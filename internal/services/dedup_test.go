@@ -0,0 +1,94 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+)
+
+// testPatternImage encodes a PNG with count of vertical stripes, scaled to size
+func testPatternImage(t *testing.T, w, h, stripes int) []byte {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := (x*stripes/w%2)*200 + y*50/h
+			img.SetGray(x, y, color.Gray{Y: uint8(v)})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDedup(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	first := testYOLOSource(t, fs, "first", map[string][]string{"train": {"a", "b"}})
+	second := testYOLOSource(t, fs, "second", map[string][]string{"train": {"c"}, "valid": {"d"}})
+
+	afero.WriteFile(fs, "first/train/images/a.png", testPatternImage(t, 64, 32, 3), 0644)
+	afero.WriteFile(fs, "first/train/images/b.png", testPatternImage(t, 64, 32, 10), 0644)
+	afero.WriteFile(fs, "second/train/images/c.png", testPatternImage(t, 64, 32, 3), 0644)
+	afero.WriteFile(fs, "second/valid/images/d.png", testPatternImage(t, 128, 64, 3), 0644)
+
+	conf := &config.Config{
+		Dest:    "dst",
+		Sources: []config.Source{first, second},
+		Dedup:   config.Dedup{Enabled: true, Perceptual: true, Threshold: 4, Policy: config.DedupKeepLargest},
+	}
+	c := testCollector(t, fs, conf)
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := afero.Exists(fs, "dst/"+DuplicatesFile); !ok {
+		t.Error("duplicates report not written")
+	}
+
+	if sum := c.summary["train"]; sum.Success != 1 || sum.Duplicates != 2 {
+		t.Errorf("train got success %d duplicates %d, want 1 and 2", sum.Success, sum.Duplicates)
+	}
+	if sum := c.summary["valid"]; sum.Success != 1 {
+		t.Errorf("valid got success %d, want largest copy kept", sum.Success)
+	}
+}
+
+func TestGroupFingerprintsThreshold(t *testing.T) {
+	// Second hash differs from the first by 12 bits spread over every band
+	near := func(a, b uint64) []*fingerprint {
+		return []*fingerprint{
+			{item: &DatasetItem{Hash: "a"}, phash: a, decoded: true},
+			{item: &DatasetItem{Hash: "b"}, phash: b, decoded: true},
+		}
+	}
+	var spread uint64
+	for i := 0; i < 12; i++ {
+		spread |= 1 << (i*5 + 4)
+	}
+
+	tests := []struct {
+		name      string
+		fps       []*fingerprint
+		threshold int
+		grouped   bool
+	}{
+		{"above bands", near(0, spread), 12, true},
+		{"beyond threshold", near(0, spread), 11, false},
+		{"default", near(0, 0b11111), 0, true},
+		{"beyond default", near(0, 0b111111), 0, false},
+		{"identical only", near(0, 1), -1, false},
+		{"identical", near(7, 7), -1, true},
+	}
+	for _, tt := range tests {
+		if got := len(groupFingerprints(tt.fps, true, tt.threshold)) == 1; got != tt.grouped {
+			t.Errorf("%s: got grouped %v, want %v", tt.name, got, tt.grouped)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// destFilenamePattern matches filenames given by DatasetItem.SetNewFilename, e.g. "train_12.jpg"
+var destFilenamePattern = regexp.MustCompile(`^(\w+?)_(\d+)\.\w+$`)
+
+// MergeClasses returns existing classes extended by every configured class not declared yet,
+// so class indexes of labels already written stay valid.
+func MergeClasses(existing, classes []string) []string {
+	merged := slices.Clone(existing)
+	for _, cls := range classes {
+		if !slices.Contains(merged, cls) {
+			merged = append(merged, cls)
+		}
+	}
+	return merged
+}
+
+// ScanLastIds returns the highest item id per category of files already written on dest
+func ScanLastIds(fs afero.Fs, dest string) (map[utils.Category]int, error) {
+	ids := make(map[utils.Category]int)
+	err := afero.Walk(fs, dest, func(_ string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		m := destFilenamePattern.FindStringSubmatch(info.Name())
+		if m == nil {
+			return nil
+		}
+		cat := utils.FindCategory(m[1])
+		if cat == nil || string(*cat) != m[1] {
+			return nil
+		}
+		if id, err := strconv.Atoi(m[2]); err == nil {
+			ids[*cat] = max(ids[*cat], id)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return ids, nil
+	}
+	return ids, err
+}
+
+// loadDestination prepares appending onto dataset already written on destination.
+// Destination classes keep existing class order, extended by configured classes not declared yet.
+func (c *Collector) loadDestination() error {
+	existing, err := c.output.Load(c.fs, c.conf.Dest)
+	if err != nil {
+		return err
+	}
+
+	var (
+		classes = c.conf.Classes
+		form    string
+	)
+	if existing != nil {
+		classes, form = MergeClasses(existing.Names, c.conf.Classes), existing.NamesForm
+		if !slices.Equal(classes, c.conf.Classes) {
+			log.Warn().
+				Strs("existing", existing.Names).
+				Strs("classes", classes).
+				Msg("Destination classes differ from config, keep existing class order")
+		}
+	}
+	c.datasetConf = config.NewDataset(classes...)
+	c.datasetConf.NamesForm = form
+
+	ids, err := ScanLastIds(c.fs, c.conf.Dest)
+	if err != nil {
+		return err
+	}
+	for cat, id := range ids {
+		c.increments[cat] = utils.NewIncrement(id)
+	}
+
+	// Keep crediting sources collected before
+	sources, err := LoadAttribution(c.fs, c.conf.Dest)
+	if err != nil {
+		return err
+	}
+	c.attribution.merge(sources)
+	log.Info().Any("lastIds", ids).Msg("Append onto existing destination")
+
+	return nil
+}
+
+// removePending removes files of items interrupted while being written, possibly truncated,
+// so their source items are collected again. Only files named by item id are removed,
+// annotations shared by every item are rewritten on close.
+func (c *Collector) removePending() error {
+	pending := c.manifest.Pending()
+	for _, e := range pending {
+		for _, dst := range []string{e.Dst, e.Label} {
+			if dst == "" || !destFilenamePattern.MatchString(path.Base(dst)) {
+				continue
+			}
+			if err := c.fs.Remove(dst); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	if len(pending) > 0 {
+		log.Warn().Int("items", len(pending)).Msg("Remove items interrupted while being written")
+	}
+	return nil
+}
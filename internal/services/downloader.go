@@ -1,6 +1,245 @@
 package services
 
-func Download(url string) {
-	
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// Download defaults
+const (
+	DefaultDownloadRetries = 3
+	downloadPartExt        = ".part"
+)
+
+var (
+	// downloadBackoff is delay before the first retry, doubled on every retry
+	downloadBackoff = time.Second
+	// downloadTimeout aborts a request waiting that long for its response or its next body bytes,
+	// so stalled connection is retried instead of hanging forever
+	downloadTimeout = 30 * time.Second
+)
+
+// errChecksum reports downloaded content not matching its checksum
+var errChecksum = errors.New("checksum mismatch")
+
+// Download fetches archive of url into cache directory, returning path of cached archive.
+// Archive is cached by its checksum when given, otherwise by url, so an archive already cached is
+// not downloaded again. Interrupted download is resumed from its partial file, failed requests are
+// retried with exponential backoff. Archive is verified against checksum, then renamed into place
+// with the extension of its detected archive type.
+func Download(fs afero.Fs, url, checksum string, conf config.Download) (string, error) {
+	cache, err := downloadCache(conf)
+	if err != nil {
+		return "", err
+	}
+	if err := fs.MkdirAll(cache, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	key := strings.ToLower(checksum)
+	if key == "" {
+		h := sha256.Sum256([]byte(url))
+		key = hex.EncodeToString(h[:])
+	}
+	base := path.Join(cache, key)
+
+	// Local mirror already holds archive
+	for _, ext := range archiveExts {
+		if ok, _ := afero.Exists(fs, base+ext); ok {
+			log.Info().Str("url", url).Str("src", base+ext).Msg("Source archive already downloaded")
+			return base + ext, nil
+		}
+	}
+
+	retries := conf.Retries
+	switch {
+	case retries == 0:
+		retries = DefaultDownloadRetries
+	case retries < 0:
+		retries = 0
+	}
+
+	part := base + downloadPartExt
+	log.Info().Str("url", url).Str("dst", part).Msg("Downloading source archive")
+	for attempt := 0; ; attempt++ {
+		err = fetch(fs, url, part)
+		if err == nil || !retryable(err) || attempt >= retries {
+			break
+		}
+
+		delay := downloadBackoff << attempt
+		log.Warn().Err(err).Int("attempt", attempt+1).Dur("retryIn", delay).Msg("Download failed, retrying")
+		time.Sleep(delay)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sum, _, err := hashFile(fs, part)
+	if err != nil {
+		return "", err
+	}
+	if checksum != "" && !strings.EqualFold(sum, checksum) {
+		fs.Remove(part)
+		return "", fmt.Errorf("%w: %s got sha256 %s, want %s", errChecksum, url, sum, checksum)
+	}
+
+	ext, err := archiveType(fs, part)
+	if err != nil {
+		return "", err
+	}
+	if err := fs.Rename(part, base+ext); err != nil {
+		return "", err
+	}
+
+	log.Info().Str("url", url).Str("src", base+ext).Msg("Source archive downloaded")
+	return base + ext, nil
+}
+
+// archiveExts lists extensions of cached archives, by detected archive type
+var archiveExts = []string{".zip", ".tar.gz", ".tar"}
+
+// downloadCache returns cache directory of downloaded archives
+func downloadCache(conf config.Download) (string, error) {
+	if conf.Cache != "" {
+		return conf.Cache, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, "dataset_collector"), nil
+}
+
+// httpError is a failed download response
+type httpError struct {
+	status int
+	url    string
+}
+
+func (e httpError) Error() string {
+	return fmt.Sprintf("download %s: %d %s", e.url, e.status, http.StatusText(e.status))
+}
+
+// retryable reports whether download failure may succeed on retry:
+// network errors, server errors, timeouts and rate limits
+func retryable(err error) bool {
+	var h httpError
+	if !errors.As(err, &h) {
+		return true
+	}
+	return h.status >= 500 || h.status == http.StatusRequestTimeout || h.status == http.StatusTooManyRequests
+}
+
+// fetch downloads url into part, resuming from its size when server supports ranges.
+// Request is canceled once it makes no progress for downloadTimeout, keeping bytes already written.
+func fetch(fs afero.Fs, url, part string) error {
+	var offset int64
+	if info, err := fs.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stall := time.AfterFunc(downloadTimeout, cancel)
+	defer stall.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return stallError(ctx, url, err)
+	}
+	defer resp.Body.Close()
+
+	flag := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flag |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// Partial file already holds whole content
+		return nil
+	case resp.StatusCode == http.StatusOK:
+		// Server ignored range, download again from start
+		flag |= os.O_TRUNC
+	default:
+		return httpError{status: resp.StatusCode, url: url}
+	}
+
+	f, err := fs.OpenFile(part, flag, 0644)
+	if err != nil {
+		return err
+	}
+	body := progressReader{r: resp.Body, progress: func() { stall.Reset(downloadTimeout) }}
+	if _, err := copyBuffer(f, body); err != nil {
+		f.Close()
+		return stallError(ctx, url, err)
+	}
+	return f.Close()
 }
 
+// stallError reports err of request canceled by its stall timer as a timeout
+func stallError(ctx context.Context, url string, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("download %s: no progress for %s: %w", url, downloadTimeout, err)
+	}
+	return err
+}
+
+// progressReader calls progress on every read returning data
+type progressReader struct {
+	r        io.Reader
+	progress func()
+}
+
+func (p progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.progress()
+	}
+	return n, err
+}
+
+// archiveType returns archive extension of file by its magic bytes
+func archiveType(fs afero.Fs, src string) (string, error) {
+	f, err := fs.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 262)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return ".zip", nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return ".tar.gz", nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return ".tar", nil
+	}
+	return "", fmt.Errorf("%s is not a zip or tar archive", src)
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+)
+
+// noBackoff retries downloads at once and aborts stalled ones after timeout, until test ends
+func noBackoff(t *testing.T, timeout time.Duration) {
+	backoff, stall := downloadBackoff, downloadTimeout
+	t.Cleanup(func() { downloadBackoff, downloadTimeout = backoff, stall })
+	downloadBackoff, downloadTimeout = 0, timeout
+}
+
+func TestDownload(t *testing.T) {
+	noBackoff(t, downloadTimeout)
+
+	var (
+		archive = afero.NewMemMapFs()
+		label   = []byte("1 0.5 0.5 0.2 0.2")
+	)
+	testArchive(t, archive, "src.zip", map[string][]byte{
+		"data.yaml":          []byte(`names: ["bus", "car", "van"]`),
+		"train/images/a.png": testImage(t, 20, 10),
+		"train/labels/a.txt": label,
+	})
+	body, _ := afero.ReadFile(archive, "src.zip")
+	sum := sha256.Sum256(body)
+	checksum := hex.EncodeToString(sum[:])
+
+	// Server fails the first request, then cuts the second one halfway, serving ranges afterwards
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case 2:
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body[:len(body)/2])
+			return
+		}
+		start := 0
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Write(body[start:])
+	}))
+	defer srv.Close()
+
+	fs := afero.NewMemMapFs()
+	src := config.Source{URL: srv.URL + "/export.zip", SHA256: checksum}
+	src.ClassSync.UnmarshalJSON([]byte(`{"car": ["car"]}`))
+	conf := &config.Config{Dest: "dst", Sources: []config.Source{src}, Download: config.Download{Cache: "cache"}}
+
+	c := testCollector(t, fs, conf)
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+	if c.summary["train"].Success != 1 {
+		t.Errorf("got %d collected, want 1", c.summary["train"].Success)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+	if ok, _ := afero.Exists(fs, "cache/"+checksum+".zip"); !ok {
+		t.Error("cached archive not found")
+	}
+
+	// Cached archive is reused without request
+	if _, err := Download(fs, src.URL, checksum, conf.Download); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("got %d requests, want cached archive reused", n)
+	}
+
+	// Mismatched checksum is rejected, dropping downloaded file
+	wrong := strings.Repeat("0", 64)
+	if _, err := Download(fs, src.URL, wrong, conf.Download); !errors.Is(err, errChecksum) {
+		t.Errorf("got %v, want checksum mismatch", err)
+	}
+	if ok, _ := afero.Exists(fs, "cache/"+wrong+downloadPartExt); ok {
+		t.Error("mismatched download kept")
+	}
+}
+
+func TestDownloadStalled(t *testing.T) {
+	noBackoff(t, 100*time.Millisecond)
+
+	// Server stalls halfway on the first request, serving ranges afterwards
+	var (
+		body     = bytes.Repeat([]byte("PK\x03\x04"), 1024)
+		requests atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body[:len(body)/2])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		start := 0
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Write(body[start:])
+	}))
+	defer srv.Close()
+
+	fs := afero.NewMemMapFs()
+	dst, err := Download(fs, srv.URL, "", config.Download{Cache: "cache"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := afero.ReadFile(fs, dst); !bytes.Equal(got, body) {
+		t.Errorf("got %d bytes, want %d resumed after stall", len(got), len(body))
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
+
+func TestDownloadNoRetries(t *testing.T) {
+	noBackoff(t, downloadTimeout)
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if _, err := Download(afero.NewMemMapFs(), srv.URL, "", config.Download{Cache: "cache", Retries: -1}); err == nil {
+		t.Error("got nil error, want unavailable")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("got %d requests, want 1 without retries", n)
+	}
+}
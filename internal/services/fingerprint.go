@@ -0,0 +1,242 @@
+package services
+
+import (
+	"image"
+	"sort"
+	"sync"
+
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultPerceptualThreshold is hamming distance of near duplicates when threshold is 0,
+// catching re-encoded or slightly resized copies of 64 bit dHash
+const DefaultPerceptualThreshold = 5
+
+// maxBands caps bands of perceptual hashes to 8 bits each, above which every pair is compared instead
+const maxBands = 8
+
+// fingerprint identifies image content of a dataset item
+type fingerprint struct {
+	item    *DatasetItem
+	pixels  int
+	phash   uint64
+	decoded bool
+	err     error
+}
+
+// fingerprints streams every image of items, hashing its content.
+// Perceptual hash is computed only when enabled, as it requires decoding the image.
+func (c Collector) fingerprints(items []*DatasetItem, perceptual bool) []*fingerprint {
+	var (
+		wg  sync.WaitGroup
+		fps = make([]*fingerprint, len(items))
+	)
+	for i, item := range items {
+		fps[i] = &fingerprint{item: item}
+
+		wg.Add(1)
+		c.pool.Submit(func() {
+			defer wg.Done()
+
+			fp := fps[i]
+			if item.Hash, _, fp.err = hashFile(c.fs, item.Image.SrcPath); fp.err != nil {
+				return
+			}
+
+			f, err := c.fs.Open(item.Image.SrcPath)
+			if err != nil {
+				fp.err = err
+				return
+			}
+			defer f.Close()
+
+			// Undecodable image still groups by identical content
+			if !perceptual {
+				if conf, _, err := image.DecodeConfig(f); err == nil {
+					fp.pixels = conf.Width * conf.Height
+				}
+				return
+			}
+
+			decodeSlots <- struct{}{}
+			defer func() { <-decodeSlots }()
+			if img, _, err := image.Decode(f); err == nil {
+				fp.pixels = img.Bounds().Dx() * img.Bounds().Dy()
+				fp.phash = utils.DHash(img)
+				fp.decoded = true
+			}
+		})
+	}
+	wg.Wait()
+
+	for _, fp := range fps {
+		if fp.err != nil {
+			log.Warn().Err(fp.err).Str("src", fp.item.Image.SrcPath).Msg("Failed fingerprint image")
+		}
+	}
+	return fps
+}
+
+// groupFingerprints groups items with identical content, or with perceptual hashes
+// within threshold when perceptual. Threshold 0 uses DefaultPerceptualThreshold, -1 only groups identical
+// perceptual hashes. Returns groups of at least two fingerprint indexes, each sorted.
+func groupFingerprints(fps []*fingerprint, perceptual bool, threshold int) [][]int {
+	switch {
+	case threshold == 0:
+		threshold = DefaultPerceptualThreshold
+	case threshold < 0:
+		threshold = 0
+	}
+
+	parent := make([]int, len(fps))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(a, b int) {
+		if ra, rb := find(a), find(b); ra != rb {
+			parent[max(ra, rb)] = min(ra, rb)
+		}
+	}
+
+	// Identical content
+	hashes := make(map[string]int)
+	for i, fp := range fps {
+		if fp.err != nil {
+			continue
+		}
+		if j, ok := hashes[fp.item.Hash]; ok {
+			union(j, i)
+			continue
+		}
+		hashes[fp.item.Hash] = i
+	}
+
+	// Near duplicates, split hashes into threshold+1 bands so candidates within threshold share at least
+	// one band. Narrower bands match most hashes anyway, so above maxBands every pair is compared.
+	if perceptual {
+		var candidates []int
+		for i, fp := range fps {
+			if fp.err == nil && fp.decoded && hashes[fp.item.Hash] == i {
+				candidates = append(candidates, i)
+			}
+		}
+
+		if bands := threshold + 1; bands <= maxBands {
+			buckets := make(map[[2]uint64][]int)
+			for _, i := range candidates {
+				for b := 0; b < bands; b++ {
+					var (
+						width = 64 / bands
+						mask  = uint64(1)<<width - 1
+						key   = [2]uint64{uint64(b), fps[i].phash >> (b * width) & mask}
+					)
+					for _, j := range buckets[key] {
+						if utils.HammingDistance(fps[j].phash, fps[i].phash) <= threshold {
+							union(j, i)
+						}
+					}
+					buckets[key] = append(buckets[key], i)
+				}
+			}
+		} else {
+			for x, i := range candidates {
+				for _, j := range candidates[:x] {
+					if utils.HammingDistance(fps[j].phash, fps[i].phash) <= threshold {
+						union(j, i)
+					}
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	for i, fp := range fps {
+		if fp.err == nil {
+			root := find(i)
+			members[root] = append(members[root], i)
+		}
+	}
+
+	var groups [][]int
+	for _, g := range members {
+		if len(g) > 1 {
+			groups = append(groups, g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
+}
+
+// screenItems fingerprints items of every source, dropping duplicates, re-splitting categories
+// and resolving leakage across categories as configured. Returns the items to collect per source.
+func (c *Collector) screenItems(sources [][]*DatasetItem) [][]*DatasetItem {
+	var all []*DatasetItem
+	for _, items := range sources {
+		// Sort for stable source order, as source readers may list items on any order
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Image.SrcPath < items[j].Image.SrcPath })
+		all = append(all, items...)
+	}
+
+	var fps []*fingerprint
+	if c.conf.Dedup.Enabled || c.conf.Leakage.Enabled {
+		fps = c.fingerprints(all, c.conf.Dedup.Perceptual || c.conf.Leakage.Perceptual)
+	}
+
+	dropped := make(map[*DatasetItem]bool)
+	if c.conf.Dedup.Enabled {
+		var duplicates []Duplicate
+		dropped, duplicates = c.dedup(fps, c.conf.Dedup)
+		log.Info().Int("dropped", len(duplicates)).Msg("Deduplicate images across sources")
+
+		if c.conf.DryRun {
+			c.plan.Duplicates = duplicates
+		} else if err := SaveDuplicates(c.fs, duplicates, c.conf.Dest); err != nil {
+			log.Error().Err(err).Msg("Failed write duplicates report")
+		}
+
+		kept := fps[:0:0]
+		for _, fp := range fps {
+			if !dropped[fp.item] {
+				kept = append(kept, fp)
+			}
+		}
+		fps = kept
+	}
+
+	screened := make([][]*DatasetItem, len(sources))
+	for i, items := range sources {
+		for _, item := range items {
+			if !dropped[item] {
+				screened[i] = append(screened[i], item)
+			}
+		}
+	}
+
+	groups := c.groupItems(screened)
+	if c.conf.Split.Enabled {
+		c.resplit(groups, c.conf.Split)
+	}
+
+	if c.conf.Leakage.Enabled {
+		leaks := c.detectLeakage(fps, groups, c.conf.Leakage)
+		if len(leaks) > 0 {
+			log.Warn().Int("leaks", len(leaks)).Str("action", c.conf.Leakage.Action).Msg("Images found on more than one category")
+		}
+
+		if c.conf.DryRun {
+			c.plan.Leaks = leaks
+		} else if err := SaveLeaks(c.fs, leaks, c.conf.Dest); err != nil {
+			log.Error().Err(err).Msg("Failed write leakage report")
+		}
+	}
+
+	return screened
+}
@@ -0,0 +1,33 @@
+package services
+
+// itemGroup is a set of items of a source derived from the same original image
+type itemGroup struct {
+	source int
+	items  []*DatasetItem
+}
+
+// groupItems groups items of every source by group key of their image filename.
+// Every item is its own group when grouping is disabled.
+// Groups are scoped per source, as unrelated sources may reuse the same filenames.
+func (c Collector) groupItems(sources [][]*DatasetItem) []*itemGroup {
+	var groups []*itemGroup
+	for i, items := range sources {
+		keys := make(map[string]*itemGroup)
+		for _, item := range items {
+			if c.grouper == nil {
+				groups = append(groups, &itemGroup{source: i, items: []*DatasetItem{item}})
+				continue
+			}
+
+			key := c.grouper.Key(item.Image.SrcFilename)
+			g, ok := keys[key]
+			if !ok {
+				g = &itemGroup{source: i}
+				keys[key] = g
+				groups = append(groups, g)
+			}
+			g.items = append(g.items, item)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"strings"
+)
+
+// Image rejection reasons, counted on failure summary
+const (
+	ImageCorrupt  = "corrupt image"
+	ImageUnknown  = "unknown image format"
+	ImageMismatch = "image format mismatch"
+)
+
+// ImageError is an image rejected by verification
+type ImageError struct {
+	Reason string
+	Err    error
+}
+
+func (e ImageError) Error() string { return e.Reason + ": " + e.Err.Error() }
+
+func (e ImageError) Unwrap() error { return e.Err }
+
+// imageFormats maps image extensions to their decoded format name
+var imageFormats = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".png":  "png",
+	".gif":  "gif",
+	".bmp":  "bmp",
+	".tif":  "tiff",
+	".tiff": "tiff",
+	".webp": "webp",
+}
+
+// VerifyImage decodes image read from r, only its header when headerOnly, and ensures
+// its format matches filename extension. Returns real width and height of image.
+func VerifyImage(r io.Reader, filename string, headerOnly bool) (width, height int, err error) {
+	var format string
+	if headerOnly {
+		var conf image.Config
+		if conf, format, err = image.DecodeConfig(r); err == nil {
+			width, height = conf.Width, conf.Height
+		}
+	} else {
+		var img image.Image
+		if img, format, err = image.Decode(r); err == nil {
+			width, height = img.Bounds().Dx(), img.Bounds().Dy()
+		}
+	}
+
+	switch {
+	case err == image.ErrFormat:
+		return 0, 0, ImageError{Reason: ImageUnknown, Err: err}
+	case err != nil:
+		return 0, 0, ImageError{Reason: ImageCorrupt, Err: err}
+	case width <= 0 || height <= 0:
+		return 0, 0, ImageError{Reason: ImageCorrupt, Err: fmt.Errorf("invalid size %dx%d", width, height)}
+	}
+
+	ext := strings.ToLower(path.Ext(filename))
+	if want, ok := imageFormats[ext]; ok && want != format {
+		return 0, 0, ImageError{Reason: ImageMismatch, Err: fmt.Errorf("%s extension holds %s image", ext, format)}
+	}
+	return width, height, nil
+}
+
+// Size returns size of image item, recorded by verification or read from image header
+func (i *Item) Size() (int, int, error) {
+	if i.Width > 0 && i.Height > 0 {
+		return i.Width, i.Height, nil
+	}
+	return imageSize(i.Data)
+}
+
+// verifyImage verifies image of item streamed from source, recording its real size.
+// Dry-run only reads image header.
+func (c Collector) verifyImage(item *DatasetItem) (err error) {
+	f, err := c.fs.Open(item.Image.SrcPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if !c.conf.DryRun {
+		decodeSlots <- struct{}{}
+		defer func() { <-decodeSlots }()
+	}
+
+	item.Image.Width, item.Image.Height, err = VerifyImage(f, item.Image.SrcFilename, c.conf.DryRun)
+	return err
+}
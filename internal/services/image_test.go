@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+)
+
+func TestCollectVerifyImage(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	src := testYOLOSource(t, fs, "src", map[string][]string{"train": {"a", "truncated", "html"}})
+
+	img := testImage(t, 20, 10)
+	afero.WriteFile(fs, "src/train/images/truncated.png", img[:len(img)/2], 0644)
+	afero.WriteFile(fs, "src/train/images/html.png", []byte("<html>Not Found</html>"), 0644)
+
+	// PNG image named as JPEG
+	afero.WriteFile(fs, "src/train/images/mismatch.jpg", img, 0644)
+	afero.WriteFile(fs, "src/train/labels/mismatch.txt", []byte("1 0.5 0.5 0.2 0.2"), 0644)
+
+	c := testCollector(t, fs, &config.Config{Dest: "dst", Sources: []config.Source{src}})
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := c.summary["train"]
+	if sum.Success != 1 || sum.Failed != 3 {
+		t.Errorf("got success %d failed %d, want 1 and 3", sum.Success, sum.Failed)
+	}
+	for reason, want := range map[string]int{ImageCorrupt: 1, ImageUnknown: 1, ImageMismatch: 1} {
+		if got := sum.Rejected[reason]; got != want {
+			t.Errorf("got %d %q, want %d", got, reason, want)
+		}
+	}
+}
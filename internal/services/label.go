@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+)
+
+// Label problem actions
+const (
+	LabelRejected = "rejected"
+	LabelClipped  = "clipped"
+	LabelDropped  = "dropped"
+)
+
+// errLabelRejected reports item rejected by invalid label lines
+var errLabelRejected = errors.New("Label is invalid")
+
+// LabelProblem is an invalid label line, with action taken on it
+type LabelProblem struct {
+	Line   int
+	Msg    string
+	Action string
+}
+
+// LabelCheck is the result of validating label lines
+type LabelCheck struct {
+	Data     []byte
+	Problems []LabelProblem
+	// Repaired is count of objects clipped into image bounds
+	Repaired int
+	// Dropped is count of objects removed from label
+	Dropped int
+}
+
+// ValidateLabel checks every YOLO label line of label type: point count, class index, finite coordinates
+// within [0,1] and non-zero area. Blank lines are removed. Invalid lines are handled by mode,
+// returning errLabelRejected on reject mode with problems found.
+func ValidateLabel(body []byte, mode, labelType string) (LabelCheck, error) {
+	var (
+		check LabelCheck
+		lines []string
+	)
+	for i, line := range strings.Split(string(body), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		res, clipped, msg := validateLabelLine(fields, labelType, mode == config.LabelClip)
+		switch {
+		case msg == "":
+			lines = append(lines, res)
+			continue
+		case clipped:
+			check.Repaired++
+			check.Problems = append(check.Problems, LabelProblem{Line: i + 1, Msg: msg, Action: LabelClipped})
+			lines = append(lines, res)
+		case mode == "" || mode == config.LabelReject:
+			check.Problems = append(check.Problems, LabelProblem{Line: i + 1, Msg: msg, Action: LabelRejected})
+		default:
+			check.Dropped++
+			check.Problems = append(check.Problems, LabelProblem{Line: i + 1, Msg: msg, Action: LabelDropped})
+		}
+	}
+
+	if len(check.Problems) > 0 && (mode == "" || mode == config.LabelReject) {
+		return check, errLabelRejected
+	}
+
+	check.Data = []byte(strings.Join(lines, "\n"))
+	return check, nil
+}
+
+// validateLabelLine returns problem of label line, empty when valid.
+// When clip, object out of bounds is clipped into image, returning the repaired line.
+func validateLabelLine(fields []string, labelType string, clip bool) (line string, clipped bool, problem string) {
+	coords := len(fields) - 1
+	switch labelType {
+	case config.LabelTypeSegment:
+		if coords < 6 || coords%2 != 0 {
+			return "", false, fmt.Sprintf("expected class with at least 3 points, got %d values", len(fields))
+		}
+	case config.LabelTypeOBB:
+		if coords != 8 {
+			return "", false, fmt.Sprintf("expected class with 4 points, got %d values", len(fields))
+		}
+	default:
+		if coords != 4 {
+			return "", false, fmt.Sprintf("expected 5 values, got %d", len(fields))
+		}
+	}
+	if id, err := strconv.Atoi(fields[0]); err != nil || id < 0 {
+		return "", false, fmt.Sprintf("invalid class index %q", fields[0])
+	}
+
+	v := make([]float64, coords)
+	for i := range v {
+		f, err := strconv.ParseFloat(fields[i+1], 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", false, fmt.Sprintf("invalid coordinate %q", fields[i+1])
+		}
+		v[i] = f
+	}
+
+	if coords == 4 {
+		return validateBox(fields, v, clip)
+	}
+	return validatePoints(fields, v, clip)
+}
+
+// validateBox checks box of center, width and height
+func validateBox(fields []string, v []float64, clip bool) (line string, clipped bool, problem string) {
+	x, y, w, h := v[0], v[1], v[2], v[3]
+	if w <= 0 || h <= 0 {
+		return "", false, "box has zero area"
+	}
+
+	var (
+		x1, y1 = x - w/2, y - h/2
+		x2, y2 = x + w/2, y + h/2
+	)
+	if x1 >= 0 && y1 >= 0 && x2 <= 1 && y2 <= 1 {
+		return strings.Join(fields, " "), false, ""
+	}
+
+	problem = "box out of image bounds"
+	if !clip {
+		return "", false, problem
+	}
+
+	x1, y1 = max(x1, 0), max(y1, 0)
+	x2, y2 = min(x2, 1), min(y2, 1)
+	if x2 <= x1 || y2 <= y1 {
+		return "", false, problem
+	}
+	return fmt.Sprintf("%s %.6f %.6f %.6f %.6f", fields[0], (x1+x2)/2, (y1+y2)/2, x2-x1, y2-y1), true, problem
+}
+
+// validatePoints checks polygon or oriented box points
+func validatePoints(fields []string, v []float64, clip bool) (line string, clipped bool, problem string) {
+	inside := true
+	for _, f := range v {
+		if f < 0 || f > 1 {
+			inside = false
+		}
+	}
+	if inside {
+		if polygonArea(v) == 0 {
+			return "", false, "polygon has zero area"
+		}
+		return strings.Join(fields, " "), false, ""
+	}
+
+	problem = "points out of image bounds"
+	if !clip {
+		return "", false, problem
+	}
+
+	for i := range v {
+		v[i] = min(max(v[i], 0), 1)
+	}
+	if polygonArea(v) == 0 {
+		return "", false, problem
+	}
+	return fmt.Sprintf("%s %s", fields[0], formatCoords(v)), true, problem
+}
+
+// polygonArea returns area of polygon given as x, y pairs, by shoelace formula
+func polygonArea(v []float64) float64 {
+	var area float64
+	for i := 0; i < len(v); i += 2 {
+		j := (i + 2) % len(v)
+		area += v[i]*v[j+1] - v[j]*v[i+1]
+	}
+	return math.Abs(area) / 2
+}
+
+// formatCoords joins repaired coordinates as label values
+func formatCoords(v []float64) string {
+	values := make([]string, len(v))
+	for i, f := range v {
+		values[i] = strconv.FormatFloat(f, 'f', 6, 64)
+	}
+	return strings.Join(values, " ")
+}
+
+// DetectLabelType returns label type of label lines by their value count: polygons when any line
+// holds other than a box or 4 points, oriented boxes when most lines hold 4 points, otherwise boxes.
+func DetectLabelType(labels ...[]byte) string {
+	var boxes, obb int
+	for _, body := range labels {
+		for _, line := range strings.Split(string(body), "\n") {
+			switch n := len(strings.Fields(line)); {
+			case n == 0:
+			case n == 5:
+				boxes++
+			case n == 9:
+				obb++
+			case n >= 7 && n%2 == 1:
+				return config.LabelTypeSegment
+			}
+		}
+	}
+	if obb > boxes {
+		return config.LabelTypeOBB
+	}
+	return config.LabelTypeDetect
+}
+
+// ToDetectLabel converts polygon and oriented box lines to their bounding box, keeping box lines
+func ToDetectLabel(body []byte) []byte {
+	lines := strings.Split(string(body), "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) <= 5 || len(fields)%2 == 0 {
+			continue
+		}
+
+		x1, y1, x2, y2 := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for j := 1; j < len(fields); j += 2 {
+			x, errX := strconv.ParseFloat(fields[j], 64)
+			y, errY := strconv.ParseFloat(fields[j+1], 64)
+			if errX != nil || errY != nil {
+				continue
+			}
+			x1, y1, x2, y2 = min(x1, x), min(y1, y), max(x2, x), max(y2, y)
+		}
+		if x2 < x1 || y2 < y1 {
+			continue
+		}
+		lines[i] = fmt.Sprintf("%s %.6f %.6f %.6f %.6f", fields[0], (x1+x2)/2, (y1+y2)/2, x2-x1, y2-y1)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+)
+
+func TestValidateLabel(t *testing.T) {
+	body := []byte("0 0.5 0.5 0.2 0.2\n1 0.9 0.5 0.4 0.2\n2 0.5 0.5 0 0.1\n3 0.5 abc 0.1 0.1\n4 0.5\n\n")
+
+	tests := []struct {
+		mode     string
+		data     string
+		repaired int
+		dropped  int
+		err      error
+	}{
+		{mode: config.LabelReject, err: errLabelRejected},
+		{mode: config.LabelClip, data: "0 0.5 0.5 0.2 0.2\n1 0.850000 0.500000 0.300000 0.200000", repaired: 1, dropped: 3},
+		{mode: config.LabelDropObject, data: "0 0.5 0.5 0.2 0.2", dropped: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			check, err := ValidateLabel(body, tt.mode, config.LabelTypeDetect)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if len(check.Problems) != 4 {
+				t.Errorf("got %d problems, want 4", len(check.Problems))
+			}
+			if string(check.Data) != tt.data {
+				t.Errorf("got data %q, want %q", check.Data, tt.data)
+			}
+			if check.Repaired != tt.repaired || check.Dropped != tt.dropped {
+				t.Errorf("got repaired %d dropped %d, want %d and %d", check.Repaired, check.Dropped, tt.repaired, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestSegmentLabel(t *testing.T) {
+	body := []byte("0 0.1 0.1 0.5 0.2 0.3 0.6\n1 0.2 0.2 0.4 0.2 0.4 0.4 0.2 0.4\n2 0.5 0.5 0.2 0.2")
+	if got := DetectLabelType(body); got != config.LabelTypeSegment {
+		t.Errorf("got label type %q, want %q", got, config.LabelTypeSegment)
+	}
+
+	check, err := ValidateLabel(append(body, "\n3 0.1 0.1 0.5 0.5"...), config.LabelDropObject, config.LabelTypeSegment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Dropped != 2 {
+		t.Errorf("got %d dropped, want 2", check.Dropped)
+	}
+
+	want := "0 0.300000 0.350000 0.400000 0.500000\n1 0.300000 0.300000 0.200000 0.200000"
+	if got := string(ToDetectLabel(check.Data)); got != want {
+		t.Errorf("got boxes %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"path"
+	"sort"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/goccy/go-json"
+	"github.com/spf13/afero"
+)
+
+// LeakageFile is the leakage report written on destination root
+const LeakageFile = "leakage.json"
+
+// LeakItem is a copy of leaked image with its category
+type LeakItem struct {
+	Src      string         `json:"src"`
+	Category utils.Category `json:"category"`
+}
+
+// Leak is an image found on more than one destination category
+type Leak struct {
+	Items  []LeakItem `json:"items"`
+	Reason string     `json:"reason"`
+	// Moved is the category every copy was moved to, when resolved
+	Moved utils.Category `json:"moved,omitempty"`
+}
+
+// categoryPriority breaks ties when resolving leak to a single category
+var categoryPriority = []utils.Category{utils.CategoryTrain, utils.CategoryValid, utils.CategoryTest}
+
+// LeakGroup reports items of the same group collected into more than one category
+const LeakGroup = "group"
+
+// detectLeakage finds item groups, and images with identical content or near duplicates when perceptual,
+// collected into more than one category. When action is move, every copy of leak, with every member
+// of its group, is moved into the category holding most of them, preferring train then valid.
+func (c Collector) detectLeakage(fps []*fingerprint, groups []*itemGroup, conf config.Leakage) []Leak {
+	memberOf := make(map[*DatasetItem]*itemGroup)
+	for _, g := range groups {
+		for _, item := range g.items {
+			memberOf[item] = g
+		}
+	}
+
+	resolve := func(items []*DatasetItem, reason string) (Leak, bool) {
+		var (
+			leak   = Leak{Reason: reason}
+			counts = make(map[utils.Category]int)
+		)
+		for _, item := range items {
+			counts[item.Cat]++
+			leak.Items = append(leak.Items, LeakItem{Src: item.Image.SrcPath, Category: item.Cat})
+		}
+		if len(counts) < 2 {
+			return leak, false
+		}
+
+		if conf.Action == config.LeakageMove {
+			for _, cat := range categoryPriority {
+				if counts[cat] > counts[leak.Moved] {
+					leak.Moved = cat
+				}
+			}
+			for _, item := range items {
+				for _, member := range memberOf[item].items {
+					member.SetCategory(leak.Moved)
+				}
+			}
+		}
+
+		sort.Slice(leak.Items, func(i, j int) bool { return leak.Items[i].Src < leak.Items[j].Src })
+		return leak, true
+	}
+
+	var leaks []Leak
+	for _, g := range groups {
+		if leak, ok := resolve(g.items, LeakGroup); ok {
+			leaks = append(leaks, leak)
+		}
+	}
+
+	for _, group := range groupFingerprints(fps, conf.Perceptual, conf.Threshold) {
+		var (
+			items  []*DatasetItem
+			reason = DuplicateExact
+		)
+		for _, i := range group {
+			items = append(items, fps[i].item)
+			if fps[i].item.Hash != fps[group[0]].item.Hash {
+				reason = DuplicateNear
+			}
+		}
+		if leak, ok := resolve(items, reason); ok {
+			leaks = append(leaks, leak)
+		}
+	}
+	return leaks
+}
+
+// SaveLeaks writes leakage report on dest
+func SaveLeaks(fs afero.Fs, leaks []Leak, dest string) error {
+	b, err := json.MarshalIndent(leaks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(fs, path.Join(dest, LeakageFile), b)
+}
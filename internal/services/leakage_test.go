@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+)
+
+func TestLeakageMove(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	src := testYOLOSource(t, fs, "src", map[string][]string{"train": {"a"}, "valid": {"b", "c"}})
+
+	afero.WriteFile(fs, "src/train/images/a.png", testPatternImage(t, 64, 32, 3), 0644)
+	afero.WriteFile(fs, "src/valid/images/b.png", testPatternImage(t, 64, 32, 3), 0644)
+	afero.WriteFile(fs, "src/valid/images/c.png", testPatternImage(t, 64, 32, 10), 0644)
+
+	conf := &config.Config{
+		Dest:    "dst",
+		Sources: []config.Source{src},
+		Leakage: config.Leakage{Enabled: true, Action: config.LeakageMove},
+	}
+	c := testCollector(t, fs, conf)
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.summary["train"].Success; got != 2 {
+		t.Errorf("train got %d items, want leaked copies moved to train", got)
+	}
+	if got := c.summary["valid"].Success; got != 1 {
+		t.Errorf("valid got %d items, want 1", got)
+	}
+	if ok, _ := afero.Exists(fs, "dst/"+LeakageFile); !ok {
+		t.Error("leakage report not written")
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// writeImage writes image of item on its destination path: its data when transformed, otherwise
+// linked to its source by link mode of item, or streamed from source.
+// Linking requires the OS filesystem, reflink falls back to copy.
+func writeImage(fs afero.Fs, image *Item) error {
+	if image.Data != nil {
+		return writeFile(fs, image.DstPath, image.Data)
+	}
+	if image.Link == "" || image.Link == config.LinkCopy {
+		return copyFile(fs, image.SrcPath, image.DstPath)
+	}
+
+	// Linked images are never on mounted archives
+	if m, ok := fs.(*mountFs); ok {
+		fs = m.Fs
+	}
+	if _, ok := fs.(*afero.OsFs); !ok {
+		if image.Link == config.LinkReflink {
+			return copyFile(fs, image.SrcPath, image.DstPath)
+		}
+		return fmt.Errorf("%s link mode requires OS filesystem", image.Link)
+	}
+
+	// Link fails on existing file, e.g. rewritten by resumed collection
+	if err := os.Remove(image.DstPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	switch image.Link {
+	case config.LinkHardlink:
+		return os.Link(image.SrcPath, image.DstPath)
+	case config.LinkSymlink:
+		src, err := filepath.Abs(image.SrcPath)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(src, image.DstPath)
+	case config.LinkReflink:
+		if err := reflink(image.SrcPath, image.DstPath); err != nil {
+			log.Debug().Err(err).Str("src", image.SrcPath).Msg("Reflink unsupported, copying image")
+			os.Remove(image.DstPath)
+			return copyFile(fs, image.SrcPath, image.DstPath)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported link mode %q", image.Link)
+}
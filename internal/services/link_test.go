@@ -0,0 +1,69 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+)
+
+func TestCollectLinkMode(t *testing.T) {
+	for _, mode := range []string{config.LinkHardlink, config.LinkSymlink, config.LinkReflink} {
+		t.Run(mode, func(t *testing.T) {
+			var (
+				dir = t.TempDir()
+				fs  = afero.NewOsFs()
+				dst = filepath.Join(dir, "dst")
+			)
+			for _, d := range []string{"images", "labels"} {
+				os.MkdirAll(filepath.Join(dir, "src", "train", d), os.ModePerm)
+			}
+			src := testYOLOSource(t, fs, filepath.Join(dir, "src"), map[string][]string{"train": {"a"}})
+			conf := &config.Config{Dest: dst, LinkMode: mode, Sources: []config.Source{src}}
+			if _, err := testCollector(t, fs, conf).CollectAll(); err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				srcImage = filepath.Join(dir, "src", "train", "images", "a.png")
+				dstImage = filepath.Join(dst, "train", "images", "train_1.png")
+			)
+			info, err := os.Lstat(dstImage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srcInfo, _ := os.Stat(srcImage)
+
+			switch mode {
+			case config.LinkHardlink:
+				if !os.SameFile(info, srcInfo) {
+					t.Error("image is not hard linked to source")
+				}
+			case config.LinkSymlink:
+				if info.Mode()&os.ModeSymlink == 0 {
+					t.Error("image is not symlinked to source")
+				}
+			case config.LinkReflink:
+				if info.Size() != srcInfo.Size() {
+					t.Errorf("got image of %d bytes, want %d", info.Size(), srcInfo.Size())
+				}
+			}
+
+			// Copy over linked destination replaces the link, never writing through it onto source
+			want, _ := os.ReadFile(srcImage)
+			conf = &config.Config{Dest: dst, LinkMode: config.LinkCopy, Sources: []config.Source{src}}
+			if _, err := testCollector(t, fs, conf).CollectAll(); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := os.ReadFile(srcImage); !bytes.Equal(got, want) {
+				t.Errorf("got source image of %d bytes, want %d unchanged", len(got), len(want))
+			}
+			if info, err := os.Lstat(dstImage); err != nil || !info.Mode().IsRegular() || os.SameFile(info, srcInfo) {
+				t.Error("copied image still linked to source")
+			}
+		})
+	}
+}
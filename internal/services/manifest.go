@@ -0,0 +1,237 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// ManifestFile is the manifest filename written on destination root
+const ManifestFile = "manifest.jsonl"
+
+// ManifestEntry records a dataset item written on destination
+type ManifestEntry struct {
+	Id       int            `json:"id"`
+	Src      string         `json:"src"`
+	Hash     string         `json:"hash"`
+	Dst      string         `json:"dst"`
+	Label    string         `json:"label"`
+	Category utils.Category `json:"category"`
+	Classes  CollectClasses `json:"classes"`
+	// Copy is number of oversampled copy of source image, zero for the source image itself
+	Copy int `json:"copy,omitempty"`
+	// Source is url or src of source image was collected from, as configured
+	Source string `json:"source,omitempty"`
+	// Pending records item about to be written, completed by the entry of the same destination
+	Pending bool `json:"pending,omitempty"`
+}
+
+// manifestKey keys entries by source image, with copy number of oversampled copies
+func manifestKey(src string, copy int) string {
+	if copy == 0 {
+		return src
+	}
+	return src + "#" + strconv.Itoa(copy)
+}
+
+// Manifest keeps every collected item as JSON line on destination as it goes,
+// so an interrupted collection can be resumed.
+type Manifest struct {
+	mu      *sync.Mutex
+	file    afero.File
+	entries map[string]ManifestEntry
+	lastIds map[utils.Category]int
+	// pending items never completed, keyed by destination
+	pending map[string]ManifestEntry
+}
+
+// errManifestReadOnly reports recording item on manifest loaded read-only
+var errManifestReadOnly = errors.New("manifest is read-only")
+
+func newManifest() *Manifest {
+	return &Manifest{
+		mu:      new(sync.Mutex),
+		entries: make(map[string]ManifestEntry),
+		lastIds: make(map[utils.Category]int),
+		pending: make(map[string]ManifestEntry),
+	}
+}
+
+// OpenManifest opens manifest on dest for appending. Existing entries are loaded when resume,
+// otherwise the manifest is truncated.
+func OpenManifest(fs afero.Fs, dest string, resume bool) (*Manifest, error) {
+	m := newManifest()
+
+	src := path.Join(dest, ManifestFile)
+	flag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if resume {
+		if err := m.load(fs, src); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	} else {
+		flag |= os.O_TRUNC
+	}
+
+	f, err := fs.OpenFile(src, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	m.file = f
+
+	return m, nil
+}
+
+// LoadManifest loads entries of manifest on dest without opening it for writing, e.g. to plan a dry-run.
+// Missing manifest loads no entry.
+func LoadManifest(fs afero.Fs, dest string) (*Manifest, error) {
+	m := newManifest()
+	if err := m.load(fs, path.Join(dest, ManifestFile)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Manifest) load(fs afero.Fs, src string) error {
+	f, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var e ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// Last line may be partially written when process died
+			log.Warn().Err(err).Int("line", line).Msg("Skip invalid manifest entry")
+			continue
+		}
+		if e.Pending {
+			m.pending[e.Dst] = e
+			continue
+		}
+		delete(m.pending, e.Dst)
+		m.entries[manifestKey(e.Src, e.Copy)] = e
+		m.lastIds[e.Category] = max(m.lastIds[e.Category], e.Id)
+	}
+	return scanner.Err()
+}
+
+// LastId returns the highest id recorded on category
+func (m *Manifest) LastId(cat utils.Category) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lastIds[cat]
+}
+
+// Len returns count of recorded entries
+func (m *Manifest) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.entries)
+}
+
+// Entries returns every recorded entry
+func (m *Manifest) Entries() []ManifestEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]ManifestEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Find returns entry recorded for the source image with same content hash, or for its copy
+func (m *Manifest) Find(src, hash string, copy int) (ManifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[manifestKey(src, copy)]
+	if !ok || e.Hash != hash {
+		return e, false
+	}
+	return e, true
+}
+
+// Pending returns items begun but never completed by an interrupted collection
+func (m *Manifest) Pending() []ManifestEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]ManifestEntry, 0, len(m.pending))
+	for _, e := range m.pending {
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Begin records item about to be written, so files of an item interrupted while
+// being written can be removed on resume
+func (m *Manifest) Begin(item *DatasetItem) error {
+	return m.write(ManifestEntry{
+		Id:       item.Id,
+		Src:      item.Image.SrcPath,
+		Dst:      item.Image.DstPath,
+		Label:    item.Label.DstPath,
+		Category: item.Cat,
+		Copy:     item.Copy,
+		Pending:  true,
+	})
+}
+
+// Add records collected item, appending it to manifest file
+func (m *Manifest) Add(item *DatasetItem, classes CollectClasses) error {
+	return m.write(ManifestEntry{
+		Id:       item.Id,
+		Src:      item.Image.SrcPath,
+		Hash:     item.Hash,
+		Dst:      item.Image.DstPath,
+		Label:    item.Label.DstPath,
+		Category: item.Cat,
+		Classes:  classes,
+		Copy:     item.Copy,
+		Source:   item.Source,
+	})
+}
+
+func (m *Manifest) write(e ManifestEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file == nil {
+		return errManifestReadOnly
+	}
+	if !e.Pending {
+		m.entries[manifestKey(e.Src, e.Copy)] = e
+		m.lastIds[e.Category] = max(m.lastIds[e.Category], e.Id)
+	}
+	_, err = m.file.Write(append(b, '\n'))
+	return err
+}
+
+func (m *Manifest) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file == nil {
+		return nil
+	}
+	return m.file.Close()
+}
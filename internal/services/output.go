@@ -0,0 +1,77 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// OutputWriter writes collected dataset items into a destination layout.
+// Item labels are always given as YOLO lines indexed by destination classes.
+type OutputWriter interface {
+	// Load reads dataset already written on dest to append onto, keeping its annotations kept in memory.
+	// Returns its classes, or nil when dest has no classes declared.
+	Load(fs afero.Fs, dest string) (*config.Dataset, error)
+
+	// CreateDestFolder creates the destination directory structure.
+	CreateDestFolder(fs afero.Fs, dest string) error
+
+	// CreateConfig saves destination classes into the layout dataset config.
+	CreateConfig(fs afero.Fs, dataset config.Dataset, dest string) error
+
+	// SetDestPaths sets destination paths of item on the layout, after its new filename is set.
+	SetDestPaths(item *DatasetItem)
+
+	// Write writes image and annotation of item to its destination paths.
+	Write(fs afero.Fs, item *DatasetItem) error
+
+	// Index records annotation of item already written on destination, e.g. by a resumed collection,
+	// so annotations kept in memory still cover it on Close. Items already loaded are recorded once.
+	Index(item *DatasetItem) error
+
+	// Close flushes annotations kept in memory until every item was written.
+	Close(fs afero.Fs) error
+}
+
+// NewOutputWriter returns the writer for the given output format.
+// An empty format defaults to YOLO.
+func NewOutputWriter(format string) (OutputWriter, error) {
+	switch format {
+	case "", config.FormatYOLO:
+		return yoloWriter{}, nil
+	case config.FormatCOCO:
+		return newCocoWriter(), nil
+	case config.FormatVOC:
+		return newVocWriter(), nil
+	}
+	return nil, fmt.Errorf("unsupported output format %q", format)
+}
+
+func writeFile(fs afero.Fs, path string, data []byte) error {
+	f, err := createFile(fs, path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	return err
+}
+
+// imageSize returns width and height from image header
+func imageSize(data []byte) (int, int, error) {
+	conf, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return conf.Width, conf.Height, nil
+}
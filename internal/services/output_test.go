@@ -0,0 +1,86 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/spf13/afero"
+)
+
+func testImage(t testing.TB, w, h int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCocoWriterRoundTrip(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	writer, err := NewOutputWriter(config.FormatCOCO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.CreateDestFolder(fs, "dst")
+	writer.CreateConfig(fs, *config.NewDataset("bus", "car"), "dst")
+
+	item := CreateDatasetItem("src/train", "dst", "a.png", utils.CategoryTrain)
+	item.Image.Data = testImage(t, 200, 100)
+	item.Label.Data = []byte("1 0.5 0.5 0.5 0.5")
+	item.SetNewFilename(1)
+	writer.SetDestPaths(item)
+	if err := writer.Write(fs, item); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(fs); err != nil {
+		t.Fatal(err)
+	}
+
+	src := config.Source{Src: "dst", Format: config.FormatCOCO}
+	reader, _ := NewSourceReader(src.Format)
+	if err := reader.LoadDatasetConfig(fs, &src); err != nil {
+		t.Fatal(err)
+	}
+	items, err := reader.Items(fs, src, "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Image.SrcPath != "dst/train/train_1.png" {
+		t.Fatalf("unexpected items %+v", items)
+	}
+	if got, want := string(items[0].Label.Data), "1 0.500000 0.500000 0.500000 0.500000"; got != want {
+		t.Errorf("label = %q, want %q", got, want)
+	}
+}
+
+func TestCocoWriterLoadSparse(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "dst/train/"+CocoAnnotationsFile, []byte(`{"images": [], "annotations": [],
+		"categories": [{"id": 1, "name": "bus"}, {"id": 3, "name": "car"}]}`), 0644)
+
+	writer, _ := NewOutputWriter(config.FormatCOCO)
+	dataset, err := writer.Load(fs, "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dataset.Names) != 4 || dataset.GetClassName(1) != "bus" || dataset.GetClassName(3) != "car" || dataset.GetClassName(2) != "" {
+		t.Errorf("got names %q, want bus on 1 and car on 3", dataset.Names)
+	}
+
+	// Sparse categories are written back with their ids
+	writer.CreateConfig(fs, *dataset, "dst")
+	if err := writer.Close(fs); err != nil {
+		t.Fatal(err)
+	}
+	coco, err := LoadCoco(fs, "dst/train/"+CocoAnnotationsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coco.Categories) != 2 || coco.Categories[0].Id != 1 || coco.Categories[1].Id != 3 {
+		t.Errorf("got categories %+v, want ids 1 and 3", coco.Categories)
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/goccy/go-json"
+)
+
+// PlanItem is a planned copy of dataset item from source to destination
+type PlanItem struct {
+	Src      string         `json:"src"`
+	Dst      string         `json:"dst"`
+	Label    string         `json:"label"`
+	Category utils.Category `json:"category"`
+	Classes  CollectClasses `json:"classes"`
+}
+
+// Plan is the merge result of dry-run collection, without any file written
+type Plan struct {
+	mu         *sync.Mutex
+	Items      []PlanItem         `json:"items"`
+	Summary    CategorizedSummary `json:"summary"`
+	Duplicates []Duplicate        `json:"duplicates,omitempty"`
+	Leaks      []Leak             `json:"leaks,omitempty"`
+	// Sources credited on attribution, with images each would contribute
+	Sources []SourceAttribution `json:"sources,omitempty"`
+}
+
+func NewPlan(summary CategorizedSummary) *Plan {
+	return &Plan{
+		mu:      new(sync.Mutex),
+		Summary: summary,
+	}
+}
+
+func (p *Plan) add(item *DatasetItem, classes CollectClasses) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Items = append(p.Items, PlanItem{
+		Src:      item.Image.SrcPath,
+		Dst:      item.Image.DstPath,
+		Label:    item.Label.DstPath,
+		Category: item.Cat,
+		Classes:  classes,
+	})
+}
+
+// sort orders items by category and destination id
+func (p *Plan) sort() {
+	sort.SliceStable(p.Items, func(i, j int) bool {
+		if p.Items[i].Category != p.Items[j].Category {
+			return p.Items[i].Category < p.Items[j].Category
+		}
+		return utils.NaturalLess(p.Items[i].Dst, p.Items[j].Dst)
+	})
+}
+
+// JSON returns plan as indented JSON
+func (p *Plan) JSON() ([]byte, error) {
+	p.sort()
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// Table writes per-category, per-class counts followed by every planned item as text table
+func (p *Plan) Table(w io.Writer) error {
+	p.sort()
+
+	var (
+		tw   = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		cats = make([]string, 0, len(p.Summary))
+		cls  = make(map[string]bool)
+	)
+	for cat, sum := range p.Summary {
+		cats = append(cats, string(cat))
+		for k := range sum.Classes {
+			cls[k] = true
+		}
+	}
+	sort.Strings(cats)
+
+	classes := make([]string, 0, len(cls))
+	for k := range cls {
+		classes = append(classes, k)
+	}
+	sort.Strings(classes)
+
+	fmt.Fprintf(tw, "CATEGORY\tIMAGES\tFAILED\tCAPPED\tCOPIES\t%s\n", strings.ToUpper(strings.Join(classes, "\t")))
+	for _, cat := range cats {
+		sum := p.Summary[utils.Category(cat)]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d", cat, sum.Success, sum.Failed, sum.Capped, sum.Oversampled)
+		for _, k := range classes {
+			fmt.Fprintf(tw, "\t%d", sum.Classes[k])
+		}
+		fmt.Fprintln(tw)
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "CATEGORY\tSRC\tDST\tCLASSES")
+	for _, i := range p.Items {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", i.Category, i.Src, i.Dst, i.Classes)
+	}
+
+	if len(p.Duplicates) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "DROPPED\tKEPT\tREASON\tDISTANCE")
+		for _, d := range p.Duplicates {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", d.Dropped, d.Kept, d.Reason, d.Distance)
+		}
+	}
+
+	if len(p.Leaks) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "LEAKED\tCATEGORY\tREASON\tMOVED")
+		for _, l := range p.Leaks {
+			for _, i := range l.Items {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", i.Src, i.Category, l.Reason, l.Moved)
+			}
+		}
+	}
+
+	if len(p.Sources) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "SOURCE\tLICENSE\tIMAGES")
+		for _, s := range p.Sources {
+			license := s.License
+			if license == "" {
+				license = LicenseUnknown
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\n", s.Name(), license, s.Images)
+		}
+	}
+
+	return tw.Flush()
+}
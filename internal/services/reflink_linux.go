@@ -0,0 +1,26 @@
+//go:build linux
+
+package services
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink clones src into dst sharing its extents, on filesystems supporting it, e.g. Btrfs or XFS
+func reflink(src, dst string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	d, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return unix.IoctlFileClone(int(d.Fd()), int(s.Fd()))
+}
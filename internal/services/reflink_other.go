@@ -0,0 +1,10 @@
+//go:build !linux
+
+package services
+
+import "errors"
+
+// reflink is only supported on Linux
+func reflink(src, dst string) error {
+	return errors.New("reflink is not supported on this platform")
+}
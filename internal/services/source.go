@@ -0,0 +1,31 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+)
+
+// SourceReader reads a source dataset layout into collectable dataset items.
+type SourceReader interface {
+	// LoadDatasetConfig resolves the class names used by the source labels into src.DatasetConfig.
+	LoadDatasetConfig(fs afero.Fs, src *config.Source) error
+
+	// Items lists every dataset item found on the source, with destination under dest.
+	Items(fs afero.Fs, src config.Source, dest string) ([]*DatasetItem, error)
+}
+
+// NewSourceReader returns the reader for the given source format.
+// An empty format defaults to YOLO.
+func NewSourceReader(format string) (SourceReader, error) {
+	switch format {
+	case "", config.FormatYOLO:
+		return yoloReader{}, nil
+	case config.FormatCOCO:
+		return cocoReader{}, nil
+	case config.FormatVOC:
+		return vocReader{}, nil
+	}
+	return nil, fmt.Errorf("unsupported source format %q", format)
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// splitUnit is a set of items always assigned to the same category
+type splitUnit struct {
+	items   []*DatasetItem
+	classes CollectClasses
+	rarest  int
+}
+
+// splitUnits returns every item group as unit, with classes of its items synced labels.
+// Label is kept raw on item, so collecting does not read it again.
+func (c Collector) splitUnits(groups []*itemGroup) []*splitUnit {
+	var units []*splitUnit
+	for _, g := range groups {
+		unit := &splitUnit{items: g.items, classes: make(CollectClasses)}
+		units = append(units, unit)
+
+		for _, item := range g.items {
+			if item.Label.Data == nil {
+				data, err := afero.ReadFile(c.fs, item.Label.SrcPath)
+				if err != nil {
+					continue
+				}
+				item.Label.Data = data
+			}
+			if _, classes, err := c.SyncClasses(item.Label.Data, c.sources[g.source]); err == nil {
+				for cls, n := range classes {
+					unit.classes.Incr(cls, n)
+				}
+			}
+		}
+	}
+	return units
+}
+
+// resplit pools item groups of every source and re-assigns their categories by configured ratios,
+// every item of a group into the same category.
+// When stratified, units holding rarer classes are assigned first, each into the category
+// most lacking share of its rarest class, so rare classes are spread over every category.
+func (c Collector) resplit(groups []*itemGroup, conf config.Split) {
+	var (
+		units  = c.splitUnits(groups)
+		ratios = conf.Ratios()
+		cats   []utils.Category
+		total  = make(CollectClasses)
+	)
+	for _, cat := range categoryPriority {
+		if ratios[cat] > 0 {
+			cats = append(cats, cat)
+		}
+	}
+
+	var items int
+	for _, u := range units {
+		items += len(u.items)
+		for cls, n := range u.classes {
+			total.Incr(cls, n)
+		}
+	}
+
+	// Shuffle first so units of same rarity are assigned randomly
+	rng := rand.New(rand.NewSource(conf.Seed))
+	rng.Shuffle(len(units), func(i, j int) { units[i], units[j] = units[j], units[i] })
+	if conf.Stratify {
+		for _, u := range units {
+			for cls := range u.classes {
+				if u.rarest == 0 || total[cls] < u.rarest {
+					u.rarest = total[cls]
+				}
+			}
+		}
+		// Units without classes go last
+		sort.SliceStable(units, func(i, j int) bool {
+			ri, rj := units[i].rarest, units[j].rarest
+			return ri != 0 && (rj == 0 || ri < rj)
+		})
+	}
+
+	var (
+		assigned = make(map[utils.Category]CollectClasses, len(cats))
+		counts   = make(map[utils.Category]int, len(cats))
+	)
+	for _, cat := range cats {
+		assigned[cat] = make(CollectClasses)
+	}
+	for _, u := range units {
+		var (
+			best      utils.Category
+			bestClass float64
+			bestItems float64
+		)
+		for _, cat := range cats {
+			// Missing share of rarest unit class objects, then missing items.
+			// Relative share lets categories without the class yet take it first.
+			var classDeficit float64
+			if conf.Stratify {
+				for cls := range u.classes {
+					if total[cls] == u.rarest {
+						desired := ratios[cat] * float64(total[cls])
+						classDeficit = max(classDeficit, (desired-float64(assigned[cat][cls]))/desired)
+					}
+				}
+			}
+			itemsDeficit := ratios[cat]*float64(items) - float64(counts[cat])
+
+			if best == "" || classDeficit > bestClass || (classDeficit == bestClass && itemsDeficit > bestItems) {
+				best, bestClass, bestItems = cat, classDeficit, itemsDeficit
+			}
+		}
+
+		for _, item := range u.items {
+			item.SetCategory(best)
+		}
+		counts[best] += len(u.items)
+		for cls, n := range u.classes {
+			assigned[best][cls] += n
+		}
+	}
+
+	for _, cat := range cats {
+		log.Info().Any("_category", cat).Int("items", counts[cat]).Any("xObjects", assigned[cat]).Msg("Re-split category")
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/spf13/afero"
+)
+
+func TestResplitStratified(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	var names []string
+	for i := 0; i < 20; i++ {
+		names = append(names, fmt.Sprintf("img%d", i))
+	}
+	src := testYOLOSource(t, fs, "src", map[string][]string{"train": names})
+	for _, n := range names[:3] {
+		afero.WriteFile(fs, "src/train/labels/"+n+".txt", []byte("0 0.5 0.5 0.2 0.2"), 0644)
+	}
+
+	conf := &config.Config{
+		Dest:    "dst",
+		DryRun:  true,
+		Sources: []config.Source{src},
+		Split:   config.Split{Enabled: true, Train: 8, Valid: 1, Test: 1, Seed: 7, Stratify: true},
+	}
+	c := testCollector(t, fs, conf)
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	for cat, want := range map[utils.Category]int{"train": 16, "valid": 2, "test": 2} {
+		sum := c.summary[cat]
+		if sum.Success < want-2 || sum.Success > want+2 {
+			t.Errorf("%s got %d items, want about %d", cat, sum.Success, want)
+		}
+		if sum.Classes["bus"] != 1 {
+			t.Errorf("%s got %d bus, want 1", cat, sum.Classes["bus"])
+		}
+	}
+}
+
+func TestResplitGrouped(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	var names []string
+	for i := 0; i < 10; i++ {
+		for _, hash := range []string{"0a1b", "2c3d", "4e5f"} {
+			names = append(names, fmt.Sprintf("IMG_%d_jpg.rf.%s", i, hash))
+		}
+	}
+	src := testYOLOSource(t, fs, "src", map[string][]string{"train": names})
+
+	conf := &config.Config{
+		Dest:    "dst",
+		DryRun:  true,
+		Sources: []config.Source{src},
+		Split:   config.Split{Enabled: true, Train: 6, Valid: 2, Test: 2, Seed: 3},
+		Group:   config.Group{Enabled: true},
+	}
+	c := testCollector(t, fs, conf)
+	if _, err := c.CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	grouper, _ := utils.NewGrouper(utils.RoboflowGroupPattern)
+	groups := make(map[string]utils.Category)
+	for _, item := range c.Plan().Items {
+		key := grouper.Key(path.Base(item.Src))
+		if cat, ok := groups[key]; ok && cat != item.Category {
+			t.Errorf("%s split across %s and %s", key, cat, item.Category)
+		}
+		groups[key] = item.Category
+	}
+	if len(groups) != 10 {
+		t.Errorf("got %d groups, want 10", len(groups))
+	}
+}
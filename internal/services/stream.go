@@ -0,0 +1,79 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// copyBufferSize is size of pooled buffers streaming image files
+const copyBufferSize = 256 << 10
+
+// copyBuffers pools buffers of streaming copies, shared by every worker
+var copyBuffers = sync.Pool{
+	New: func() any {
+		b := make([]byte, copyBufferSize)
+		return &b
+	},
+}
+
+// decodeSlots bounds images decoded at once to CPU count, as decoded pixels are held in memory
+// and decoding is CPU bound, so memory stays flat however many workers are collecting.
+var decodeSlots = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+// copyBuffer streams src into dst through pooled buffer
+func copyBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+
+	return io.CopyBuffer(dst, src, *buf)
+}
+
+// copyFile streams file src into dst on fs
+func copyFile(fs afero.Fs, src, dst string) error {
+	r, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := createFile(fs, dst)
+	if err != nil {
+		return err
+	}
+	if _, err := copyBuffer(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// createFile creates dst as a new file, removing existing one first, so destination linked to its source,
+// e.g. by collection in link mode, is replaced instead of written through onto source
+func createFile(fs afero.Fs, dst string) (afero.File, error) {
+	if err := fs.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return fs.Create(dst)
+}
+
+// hashFile returns hex encoded SHA-256 of file, streamed through pooled buffer, with size of file
+func hashFile(fs afero.Fs, src string) (string, int64, error) {
+	f, err := fs.Open(src)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := copyBuffer(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
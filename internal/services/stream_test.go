@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/spf13/afero"
+)
+
+// trackPeakHeap samples heap in use until stopped, returning the highest sample in MB
+func trackPeakHeap() (stop func() float64) {
+	var (
+		peak atomic.Uint64
+		done = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		var m runtime.MemStats
+		for {
+			runtime.ReadMemStats(&m)
+			if m.HeapInuse > peak.Load() {
+				peak.Store(m.HeapInuse)
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+
+	return func() float64 {
+		close(done)
+		wg.Wait()
+		return float64(peak.Load()) / (1 << 20)
+	}
+}
+
+// BenchmarkCollectItem collects 4 MB images by growing count of concurrent workers, reporting
+// peak heap growth per round of every worker collecting one item. Streamed images keep peak heap
+// flat as workers grow, bounded by decodes at once rather than by workers.
+func BenchmarkCollectItem(b *testing.B) {
+	var (
+		img = image.NewRGBA(image.Rect(0, 0, 1024, 1024))
+		buf bytes.Buffer
+	)
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	png.Encode(&buf, img)
+
+	for _, workers := range []int{8, 64, 256} {
+		b.Run(strconv.Itoa(workers)+"-workers", func(b *testing.B) {
+			dir := b.TempDir()
+			for _, d := range []string{"images", "labels"} {
+				os.MkdirAll(filepath.Join(dir, "src", "train", d), os.ModePerm)
+			}
+
+			var (
+				fs    = afero.NewOsFs()
+				src   = testYOLOSource(b, fs, filepath.Join(dir, "src"), nil)
+				train = filepath.Join(dir, "src", "train")
+			)
+			afero.WriteFile(fs, filepath.Join(train, "images", "a.png"), buf.Bytes(), 0644)
+			afero.WriteFile(fs, filepath.Join(train, "labels", "a.txt"), []byte("1 0.5 0.5 0.2 0.2"), 0644)
+			if err := src.LoadDatasetConfig(fs); err != nil {
+				b.Fatal(err)
+			}
+
+			// Every worker collects its own linked copy of the image
+			items := make([]*DatasetItem, workers)
+			for i := range items {
+				name := strconv.Itoa(i)
+				os.Link(filepath.Join(train, "images", "a.png"), filepath.Join(train, "images", name+".png"))
+				os.Link(filepath.Join(train, "labels", "a.txt"), filepath.Join(train, "labels", name+".txt"))
+				items[i] = CreateDatasetItem(train, filepath.Join(dir, "dst"), name+".png", utils.CategoryTrain)
+			}
+
+			c := testCollector(b, fs, &config.Config{Dest: filepath.Join(dir, "dst"), Workers: workers, Sources: []config.Source{src}})
+			c.createSummaries()
+			c.CreateDestFolder()
+			c.CreateConfig()
+
+			var peak float64
+			for i := 0; i < b.N; i++ {
+				// Fresh manifest, so items are collected again rather than skipped
+				b.StopTimer()
+				var err error
+				if c.manifest, err = OpenManifest(fs, c.conf.Dest, false); err != nil {
+					b.Fatal(err)
+				}
+				runtime.GC()
+				var base runtime.MemStats
+				runtime.ReadMemStats(&base)
+				stop := trackPeakHeap()
+				b.StartTimer()
+
+				var wg sync.WaitGroup
+				for _, item := range items {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := c.collectItem(item, src); err != nil {
+							b.Error(err)
+						}
+					}()
+				}
+				wg.Wait()
+
+				b.StopTimer()
+				peak = max(peak, stop()-float64(base.HeapInuse)/(1<<20))
+				c.manifest.Close()
+				b.StartTimer()
+			}
+			b.ReportMetric(peak, "peak-heap-MB")
+		})
+	}
+}
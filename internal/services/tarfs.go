@@ -0,0 +1,182 @@
+package services
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// tarEntry is an indexed tar entry, its content placed at offset of file served by tarFs
+type tarEntry struct {
+	info   os.FileInfo
+	offset int64
+}
+
+// tarFs serves indexed tar entries as read-only filesystem, reading contents on demand from file name of fs.
+// File is removed on Close when spooled.
+type tarFs struct {
+	fs      afero.Fs
+	name    string
+	spooled bool
+	entries map[string]tarEntry
+	dirs    map[string][]string
+}
+
+// newTarFs returns empty tarFs holding only its root
+func newTarFs() *tarFs {
+	t := &tarFs{
+		entries: make(map[string]tarEntry),
+		dirs:    make(map[string][]string),
+	}
+	t.entries["/"] = tarEntry{info: dirInfo("/")}
+	return t
+}
+
+// dirInfo returns info of directory name
+func dirInfo(name string) os.FileInfo {
+	return (&tar.Header{Name: name, Typeflag: tar.TypeDir, Mode: 0755}).FileInfo()
+}
+
+// index lists every entry on its parent directory, adding parents missing from archive
+func (t *tarFs) index() {
+	names := make([]string, 0, len(t.entries))
+	for name := range t.entries {
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		for ; name != "/"; name = path.Dir(name) {
+			dir := path.Dir(name)
+			t.dirs[dir] = append(t.dirs[dir], name)
+			if _, ok := t.entries[dir]; ok {
+				break
+			}
+			t.entries[dir] = tarEntry{info: dirInfo(dir)}
+		}
+	}
+	for _, children := range t.dirs {
+		sort.Strings(children)
+	}
+}
+
+// Close removes spooled file of archive
+func (t *tarFs) Close() error {
+	if !t.spooled {
+		return nil
+	}
+	return t.fs.Remove(t.name)
+}
+
+func (t *tarFs) Open(name string) (afero.File, error) {
+	name = path.Join("/", name)
+	e, ok := t.entries[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	f := &tarFile{fs: t, name: name, info: e.info}
+	if e.info.IsDir() {
+		f.SectionReader = io.NewSectionReader(strings.NewReader(""), 0, 0)
+		return f, nil
+	}
+
+	// Every file opens its own handle, as file offset is shared by reads at offset
+	var err error
+	if f.file, err = t.fs.Open(t.name); err != nil {
+		return nil, err
+	}
+	f.SectionReader = io.NewSectionReader(f.file, e.offset, e.info.Size())
+	return f, nil
+}
+
+func (t *tarFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, syscall.EPERM
+	}
+	return t.Open(name)
+}
+
+func (t *tarFs) Stat(name string) (os.FileInfo, error) {
+	e, ok := t.entries[path.Join("/", name)]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return e.info, nil
+}
+
+func (t *tarFs) Name() string { return "TarFs" }
+
+func (t *tarFs) Create(string) (afero.File, error)          { return nil, syscall.EPERM }
+func (t *tarFs) Mkdir(string, os.FileMode) error            { return syscall.EPERM }
+func (t *tarFs) MkdirAll(string, os.FileMode) error         { return syscall.EPERM }
+func (t *tarFs) Remove(string) error                        { return syscall.EPERM }
+func (t *tarFs) RemoveAll(string) error                     { return syscall.EPERM }
+func (t *tarFs) Rename(string, string) error                { return syscall.EPERM }
+func (t *tarFs) Chmod(string, os.FileMode) error            { return syscall.EPERM }
+func (t *tarFs) Chown(string, int, int) error               { return syscall.EPERM }
+func (t *tarFs) Chtimes(string, time.Time, time.Time) error { return syscall.EPERM }
+
+// tarFile is an opened tarFs entry, reading its section of archive file
+type tarFile struct {
+	*io.SectionReader
+	fs   *tarFs
+	file afero.File
+	name string
+	info os.FileInfo
+	read int
+}
+
+func (f *tarFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
+
+func (f *tarFile) Name() string { return f.name }
+
+func (f *tarFile) Stat() (os.FileInfo, error) { return f.info, nil }
+
+func (f *tarFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.info.IsDir() {
+		return nil, syscall.ENOTDIR
+	}
+
+	children := f.fs.dirs[f.name][f.read:]
+	if count > 0 {
+		if len(children) == 0 {
+			return nil, io.EOF
+		}
+		children = children[:min(count, len(children))]
+	}
+	f.read += len(children)
+
+	infos := make([]os.FileInfo, len(children))
+	for i, name := range children {
+		infos[i] = f.fs.entries[name].info
+	}
+	return infos, nil
+}
+
+func (f *tarFile) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
+
+func (f *tarFile) Sync() error { return nil }
+
+func (f *tarFile) Truncate(int64) error               { return syscall.EPERM }
+func (f *tarFile) Write([]byte) (int, error)          { return 0, syscall.EPERM }
+func (f *tarFile) WriteAt([]byte, int64) (int, error) { return 0, syscall.EPERM }
+func (f *tarFile) WriteString(string) (int, error)    { return 0, syscall.EPERM }
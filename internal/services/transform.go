@@ -0,0 +1,248 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/spf13/afero"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/tiff"
+)
+
+// defaultQuality is JPEG quality when not configured
+const defaultQuality = 90
+
+// letterboxColor pads letterboxed images, as used by YOLO trainers
+var letterboxColor = color.RGBA{R: 114, G: 114, B: 114, A: 255}
+
+// imageExts maps image format to extension of encoded images
+var imageExts = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+	"gif":  ".gif",
+	"bmp":  ".bmp",
+	"tiff": ".tiff",
+	"webp": ".webp",
+}
+
+// pointFunc maps normalized point of source image onto transformed image
+type pointFunc func(x, y float64) (float64, float64)
+
+// transformImage re-encodes image of item by configured transform, adjusting its label to match.
+// Image is kept as is when neither format nor size changes, returning whether image is changed.
+// Dry-run only sets the destination extension.
+func (c Collector) transformImage(item *DatasetItem) (bool, error) {
+	conf := c.conf.Transform
+	if conf.Format != "" {
+		item.Image.Ext = imageExts[conf.Format]
+	}
+	if c.conf.DryRun {
+		return false, nil
+	}
+
+	decodeSlots <- struct{}{}
+	defer func() { <-decodeSlots }()
+
+	data, err := afero.ReadFile(c.fs, item.Image.SrcPath)
+	if err != nil {
+		return false, err
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		fn      pointFunc
+		changed = conf.Format != "" && conf.Format != format
+	)
+	if conf.Format != "" {
+		format = conf.Format
+	}
+
+	if o := utils.ExifOrientation(data); conf.Orient != "" && o > 1 {
+		img = orientImage(img, o)
+		if conf.Orient == config.OrientStored {
+			fn = func(x, y float64) (float64, float64) { return orientPoint(o, x, y, 1, 1) }
+		}
+		changed = true
+	}
+	if side := max(img.Bounds().Dx(), img.Bounds().Dy()); conf.MaxSide > 0 && side > conf.MaxSide {
+		img = resizeImage(img, float64(conf.MaxSide)/float64(side))
+		changed = true
+	}
+	if conf.Letterbox.Width > 0 && conf.Letterbox.Height > 0 {
+		var boxed pointFunc
+		img, boxed = letterbox(img, conf.Letterbox)
+		fn = chainPoints(fn, boxed)
+		changed = true
+	}
+	if format == config.ImageJPEG && conf.Quality > 0 {
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := encodeImage(&buf, img, format, conf.Quality); err != nil {
+		return false, err
+	}
+
+	item.Image.Data = buf.Bytes()
+	item.Image.Width, item.Image.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	item.Image.Ext = imageExts[format]
+	if fn != nil {
+		item.Label.Data = TransformLabel(item.Label.Data, fn)
+	}
+	return true, nil
+}
+
+// chainPoints returns function mapping points through first then next, either may be nil
+func chainPoints(first, next pointFunc) pointFunc {
+	if first == nil {
+		return next
+	}
+	return func(x, y float64) (float64, float64) { return next(first(x, y)) }
+}
+
+// orientPoint maps point of stored image with max coordinates w and h onto its displayed orientation
+func orientPoint(o int, x, y, w, h float64) (float64, float64) {
+	switch o {
+	case 2:
+		return w - x, y
+	case 3:
+		return w - x, h - y
+	case 4:
+		return x, h - y
+	case 5:
+		return y, x
+	case 6:
+		return h - y, x
+	case 7:
+		return h - y, w - x
+	case 8:
+		return y, w - x
+	}
+	return x, y
+}
+
+// orientImage rotates and flips image into displayed orientation of EXIF orientation
+func orientImage(img image.Image, o int) image.Image {
+	var (
+		b      = img.Bounds()
+		w, h   = b.Dx(), b.Dy()
+		dw, dh = w, h
+	)
+	if o >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := orientPoint(o, float64(x), float64(y), float64(w-1), float64(h-1))
+			dst.Set(int(dx), int(dy), img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// resizeImage scales image by factor
+func resizeImage(img image.Image, scale float64) image.Image {
+	var (
+		w   = max(1, int(math.Round(float64(img.Bounds().Dx())*scale)))
+		h   = max(1, int(math.Round(float64(img.Bounds().Dy())*scale)))
+		dst = image.NewRGBA(image.Rect(0, 0, w, h))
+	)
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+	return dst
+}
+
+// letterbox fits image into size keeping aspect ratio, centered on padded borders.
+// Returns letterboxed image with function mapping normalized points onto it.
+func letterbox(img image.Image, size config.Size) (image.Image, pointFunc) {
+	var (
+		sw, sh = float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+		scale  = min(float64(size.Width)/sw, float64(size.Height)/sh)
+		w      = max(1, int(math.Round(sw*scale)))
+		h      = max(1, int(math.Round(sh*scale)))
+		left   = (size.Width - w) / 2
+		top    = (size.Height - h) / 2
+		dst    = image.NewRGBA(image.Rect(0, 0, size.Width, size.Height))
+	)
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(letterboxColor), image.Point{}, draw.Src)
+	draw.BiLinear.Scale(dst, image.Rect(left, top, left+w, top+h), img, img.Bounds(), draw.Over, nil)
+
+	fn := func(x, y float64) (float64, float64) {
+		return (x*float64(w) + float64(left)) / float64(size.Width), (y*float64(h) + float64(top)) / float64(size.Height)
+	}
+	return dst, fn
+}
+
+// encodeImage encodes image in format, with quality of JPEG encoding.
+// WebP images are encoded lossless.
+func encodeImage(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case config.ImageJPEG:
+		if quality == 0 {
+			quality = defaultQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case config.ImagePNG:
+		return png.Encode(w, img)
+	case config.ImageWebP:
+		return nativewebp.Encode(w, img, nil)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	case "bmp":
+		return bmp.Encode(w, img)
+	case "tiff":
+		return tiff.Encode(w, img, nil)
+	}
+	return fmt.Errorf("unsupported image format %q", format)
+}
+
+// TransformLabel maps every label line through fn. Polygon and oriented box points are mapped as is,
+// boxes are mapped by their corners into the bounding box of mapped corners.
+func TransformLabel(body []byte, fn pointFunc) []byte {
+	lines := strings.Split(string(body), "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || len(fields)%2 == 0 {
+			continue
+		}
+
+		v := make([]float64, len(fields)-1)
+		for j := range v {
+			v[j], _ = strconv.ParseFloat(fields[j+1], 64)
+		}
+
+		if len(v) == 4 {
+			var (
+				x1, y1 = fn(v[0]-v[2]/2, v[1]-v[3]/2)
+				x2, y2 = fn(v[0]+v[2]/2, v[1]+v[3]/2)
+			)
+			v = []float64{(x1 + x2) / 2, (y1 + y2) / 2, math.Abs(x2 - x1), math.Abs(y2 - y1)}
+		} else {
+			for j := 0; j < len(v); j += 2 {
+				v[j], v[j+1] = fn(v[j], v[j+1])
+			}
+		}
+		lines[i] = fields[0] + " " + formatCoords(v)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/spf13/afero"
+)
+
+func TestCollectTransform(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	src := testYOLOSource(t, fs, "src", map[string][]string{"train": {"a"}})
+
+	conf := &config.Config{
+		Dest:      "dst",
+		Sources:   []config.Source{src},
+		Transform: config.Transform{Enabled: true, Format: config.ImageJPEG, Letterbox: config.Size{Width: 32, Height: 32}},
+	}
+	if _, err := testCollector(t, fs, conf).CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := afero.ReadFile(fs, "dst/train/images/train_1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || img.Width != 32 || img.Height != 32 {
+		t.Errorf("got %s %dx%d, want jpeg 32x32", format, img.Width, img.Height)
+	}
+
+	// 20x10 image is scaled into 32x16, padded by 8 on top
+	label, _ := afero.ReadFile(fs, "dst/train/labels/train_1.txt")
+	want := "1 0.500000 0.500000 0.200000 0.100000\n1 0.300000 0.400000 0.100000 0.050000"
+	if string(label) != want {
+		t.Errorf("got label %q, want %q", label, want)
+	}
+}
+
+// testExifJPEG returns JPEG image with EXIF orientation tag
+func testExifJPEG(t *testing.T, w, h, orientation int) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00")
+	exif[len(exif)-7] = byte(orientation)
+	app1 := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+
+	data := buf.Bytes()
+	return append(append([]byte{0xFF, 0xD8}, app1...), data[2:]...)
+}
+
+func TestCollectOrient(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	src := testYOLOSource(t, fs, "src", nil)
+	afero.WriteFile(fs, "src/train/images/a.jpg", testExifJPEG(t, 20, 10, 6), 0644)
+	afero.WriteFile(fs, "src/train/labels/a.txt", []byte("2 0.3 0.3 0.1 0.1"), 0644)
+
+	conf := &config.Config{
+		Dest:      "dst",
+		Sources:   []config.Source{src},
+		Transform: config.Transform{Enabled: true, Orient: config.OrientStored},
+	}
+	if _, err := testCollector(t, fs, conf).CollectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := afero.ReadFile(fs, "dst/train/images/train_1.jpg")
+	if o := utils.ExifOrientation(b); o != 1 {
+		t.Errorf("got orientation %d, want tag stripped", o)
+	}
+	img, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Width != 10 || img.Height != 20 {
+		t.Errorf("got %dx%d, want 10x20", img.Width, img.Height)
+	}
+
+	// Rotated 90 degrees clockwise
+	label, _ := afero.ReadFile(fs, "dst/train/labels/train_1.txt")
+	if want := "1 0.700000 0.300000 0.100000 0.100000"; string(label) != want {
+		t.Errorf("got label %q, want %q", label, want)
+	}
+}
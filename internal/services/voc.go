@@ -0,0 +1,387 @@
+package services
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// Pascal VOC folder names
+const (
+	VocAnnotationsDir = "Annotations"
+	VocImagesDir      = "JPEGImages"
+	VocImageSetsDir   = "ImageSets/Main"
+)
+
+type VocBndBox struct {
+	Xmin float64 `xml:"xmin"`
+	Ymin float64 `xml:"ymin"`
+	Xmax float64 `xml:"xmax"`
+	Ymax float64 `xml:"ymax"`
+}
+
+type VocObject struct {
+	Name      string    `xml:"name"`
+	Difficult int       `xml:"difficult"`
+	BndBox    VocBndBox `xml:"bndbox"`
+}
+
+type VocSize struct {
+	Width  int `xml:"width"`
+	Height int `xml:"height"`
+	Depth  int `xml:"depth"`
+}
+
+type Voc struct {
+	XMLName  xml.Name    `xml:"annotation"`
+	Folder   string      `xml:"folder"`
+	Filename string      `xml:"filename"`
+	Size     VocSize     `xml:"size"`
+	Objects  []VocObject `xml:"object"`
+}
+
+func LoadVoc(fs afero.Fs, src string) (*Voc, error) {
+	f, err := afero.ReadFile(fs, src)
+	if err != nil {
+		return nil, err
+	}
+
+	voc := new(Voc)
+	if err := xml.Unmarshal(f, voc); err != nil {
+		return nil, err
+	}
+	return voc, nil
+}
+
+// YOLOLabels converts objects to normalized YOLO label lines, using classId to resolve class index.
+// VOC coordinates are 1-based pixels.
+func (v Voc) YOLOLabels(classId func(name string) int) ([]string, error) {
+	if v.Size.Width <= 0 || v.Size.Height <= 0 {
+		return nil, errors.New("Image size is missing")
+	}
+
+	var (
+		w      = float64(v.Size.Width)
+		h      = float64(v.Size.Height)
+		labels = make([]string, 0, len(v.Objects))
+	)
+	for _, o := range v.Objects {
+		b := o.BndBox
+		labels = append(labels, fmt.Sprintf("%d %.6f %.6f %.6f %.6f",
+			classId(o.Name),
+			((b.Xmin+b.Xmax)/2-1)/w,
+			((b.Ymin+b.Ymax)/2-1)/h,
+			(b.Xmax-b.Xmin)/w,
+			(b.Ymax-b.Ymin)/h,
+		))
+	}
+	return labels, nil
+}
+
+// vocReader reads Pascal VOC layout sources: `Annotations/*.xml` with `JPEGImages/`,
+// either on source root or on each category folder. On source root, categories
+// are taken from `ImageSets/Main/<category>.txt`, otherwise every item goes to train.
+type vocReader struct{}
+
+// annotations returns every annotation file on source mapped to its category
+func (r vocReader) annotations(fs afero.Fs, src string) (map[string]utils.Category, error) {
+	files := make(map[string]utils.Category)
+
+	if ok, _ := afero.DirExists(fs, path.Join(src, VocAnnotationsDir)); ok {
+		sets, _ := afero.ReadDir(fs, path.Join(src, VocImageSetsDir))
+		for _, s := range sets {
+			cat := utils.FindCategory(utils.Filename(s.Name()))
+			if s.IsDir() || path.Ext(s.Name()) != ".txt" || cat == nil {
+				continue
+			}
+
+			b, err := afero.ReadFile(fs, path.Join(src, VocImageSetsDir, s.Name()))
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range strings.Fields(string(b)) {
+				files[path.Join(src, VocAnnotationsDir, id+".xml")] = *cat
+			}
+		}
+		if len(files) > 0 {
+			return files, nil
+		}
+
+		return files, r.walk(fs, src, utils.CategoryTrain, files)
+	}
+
+	entry, err := afero.ReadDir(fs, src)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entry {
+		if cat := utils.FindCategory(e.Name()); e.IsDir() && cat != nil {
+			if err := r.walk(fs, path.Join(src, e.Name()), *cat, files); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+func (vocReader) walk(fs afero.Fs, dir string, cat utils.Category, files map[string]utils.Category) error {
+	entry, err := afero.ReadDir(fs, path.Join(dir, VocAnnotationsDir))
+	if err != nil {
+		return err
+	}
+	for _, e := range entry {
+		if !e.IsDir() && path.Ext(e.Name()) == ".xml" {
+			files[path.Join(dir, VocAnnotationsDir, e.Name())] = cat
+		}
+	}
+	return nil
+}
+
+// LoadDatasetConfig builds source class names from every object name found, sorted by name.
+func (r vocReader) LoadDatasetConfig(fs afero.Fs, src *config.Source) error {
+	files, err := r.annotations(fs, src.Src)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]bool)
+	for f := range files {
+		voc, err := LoadVoc(fs, f)
+		if err != nil {
+			log.Warn().Err(err).Str("src", f).Msg("Failed read VOC annotation")
+			continue
+		}
+		for _, o := range voc.Objects {
+			found[o.Name] = true
+		}
+	}
+
+	names := make([]string, 0, len(found))
+	for n := range found {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	src.DatasetConfig = config.NewDataset(names...)
+
+	return nil
+}
+
+func (r vocReader) Items(fs afero.Fs, src config.Source, dest string) ([]*DatasetItem, error) {
+	files, err := r.annotations(fs, src.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*DatasetItem
+	for f, cat := range files {
+		voc, err := LoadVoc(fs, f)
+		if err != nil {
+			log.Warn().Err(err).Str("src", f).Msg("Failed read VOC annotation")
+			continue
+		}
+
+		labels, err := voc.YOLOLabels(src.DatasetConfig.GetClassId)
+		if err != nil {
+			log.Warn().Err(err).Str("src", f).Msg("Failed convert VOC annotation")
+			continue
+		}
+
+		var (
+			dir      = path.Dir(path.Dir(f))
+			filename = voc.Filename
+		)
+		if filename == "" {
+			filename = utils.RealFilename(utils.Filename(path.Base(f)), ".jpg")
+		}
+
+		items = append(items, &DatasetItem{
+			SrcDir: dir,
+			DstDir: path.Join(dest, string(cat)),
+			Cat:    cat,
+			Image: &Item{
+				SrcFilename: filename,
+				SrcPath:     path.Join(dir, VocImagesDir, filename),
+			},
+			Label: &Item{
+				SrcFilename: path.Base(f),
+				SrcPath:     f,
+				Data:        []byte(strings.Join(labels, "\n")),
+			},
+		})
+	}
+
+	return items, nil
+}
+
+// vocWriter writes Pascal VOC layout destination: `Annotations/*.xml` with `JPEGImages/`,
+// categories are listed on `ImageSets/Main/<category>.txt` on Close.
+type vocWriter struct {
+	mu    *sync.Mutex
+	dest  string
+	names []string
+	sets  map[utils.Category]map[string]bool
+}
+
+func newVocWriter() *vocWriter {
+	return &vocWriter{
+		mu:   new(sync.Mutex),
+		sets: make(map[utils.Category]map[string]bool),
+	}
+}
+
+// Load keeps image sets already written on dest. VOC declares no class list, so classes are nil.
+func (w *vocWriter) Load(fs afero.Fs, dest string) (*config.Dataset, error) {
+	sets, err := afero.ReadDir(fs, path.Join(dest, VocImageSetsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, s := range sets {
+		cat := utils.FindCategory(utils.Filename(s.Name()))
+		if s.IsDir() || path.Ext(s.Name()) != ".txt" || cat == nil {
+			continue
+		}
+
+		b, err := afero.ReadFile(fs, path.Join(dest, VocImageSetsDir, s.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range strings.Fields(string(b)) {
+			w.add(*cat, id)
+		}
+	}
+	return nil, nil
+}
+
+func (w *vocWriter) CreateDestFolder(fs afero.Fs, dest string) error {
+	for _, dir := range []string{VocAnnotationsDir, VocImagesDir, VocImageSetsDir} {
+		if err := fs.MkdirAll(path.Join(dest, dir), os.ModePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateConfig keeps destination classes to resolve object names, VOC has no dataset config file.
+func (w *vocWriter) CreateConfig(fs afero.Fs, dataset config.Dataset, dest string) error {
+	w.dest = dest
+	w.names = dataset.Names
+	return nil
+}
+
+func (w *vocWriter) SetDestPaths(item *DatasetItem) {
+	item.Image.DstPath = path.Join(w.dest, VocImagesDir, item.Image.DstFilename)
+	item.Label.DstFilename = utils.RealFilename(utils.Filename(item.Image.DstFilename), ".xml")
+	item.Label.DstPath = path.Join(w.dest, VocAnnotationsDir, item.Label.DstFilename)
+}
+
+func (w *vocWriter) Write(fs afero.Fs, item *DatasetItem) error {
+	width, height, err := item.Image.Size()
+	if err != nil {
+		return err
+	}
+	boxes, err := ParseYOLOLabels(item.Label.Data)
+	if err != nil {
+		return err
+	}
+
+	voc := Voc{
+		Folder:   VocImagesDir,
+		Filename: item.Image.DstFilename,
+		Size:     VocSize{Width: width, Height: height, Depth: 3},
+	}
+	for _, b := range boxes {
+		if b.Class < 0 || b.Class >= len(w.names) {
+			return fmt.Errorf("class %d out of destination classes", b.Class)
+		}
+
+		var (
+			fw = float64(width)
+			fh = float64(height)
+		)
+		voc.Objects = append(voc.Objects, VocObject{
+			Name: w.names[b.Class],
+			BndBox: VocBndBox{
+				Xmin: math.Round((b.X-b.W/2)*fw + 1),
+				Ymin: math.Round((b.Y-b.H/2)*fh + 1),
+				Xmax: math.Round((b.X+b.W/2)*fw + 1),
+				Ymax: math.Round((b.Y+b.H/2)*fh + 1),
+			},
+		})
+	}
+
+	data, err := xml.MarshalIndent(voc, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	item.Label.Data = data
+
+	if err := writeImage(fs, item.Image); err != nil {
+		return err
+	}
+	if err := writeFile(fs, item.Label.DstPath, item.Label.Data); err != nil {
+		fs.Remove(item.Image.DstPath)
+		return err
+	}
+
+	return w.Index(item)
+}
+
+// Index lists item on image set of its category, once when already loaded from destination
+func (w *vocWriter) Index(item *DatasetItem) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.add(item.Cat, utils.Filename(item.Image.DstFilename))
+
+	return nil
+}
+
+// add lists image id on image set of cat, locked by caller
+func (w *vocWriter) add(cat utils.Category, id string) {
+	if w.sets[cat] == nil {
+		w.sets[cat] = make(map[string]bool)
+	}
+	w.sets[cat][id] = true
+}
+
+func (w *vocWriter) Close(fs afero.Fs) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for cat, set := range w.sets {
+		ids := make([]string, 0, len(set))
+		for id := range set {
+			ids = append(ids, id)
+		}
+
+		// VOC tooling expects validation set named as val
+		name := string(cat)
+		if cat == utils.CategoryValid {
+			name = "val"
+		}
+
+		sort.Strings(ids)
+		data := []byte(strings.Join(ids, "\n") + "\n")
+		if err := writeFile(fs, path.Join(w.dest, VocImageSetsDir, utils.RealFilename(name, ".txt")), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
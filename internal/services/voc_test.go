@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/spf13/afero"
+)
+
+const vocSample = `<annotation>
+	<filename>a.jpg</filename>
+	<size><width>200</width><height>100</height><depth>3</depth></size>
+	<object><name>van</name><bndbox><xmin>51</xmin><ymin>26</ymin><xmax>151</xmax><ymax>76</ymax></bndbox></object>
+	<object><name>car</name><bndbox><xmin>1</xmin><ymin>1</ymin><xmax>101</xmax><ymax>51</ymax></bndbox></object>
+</annotation>`
+
+func TestVocReader(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "src/Annotations/a.xml", []byte(vocSample), 0644)
+	afero.WriteFile(fs, "src/JPEGImages/a.jpg", []byte("img"), 0644)
+	afero.WriteFile(fs, "src/ImageSets/Main/val.txt", []byte("a\n"), 0644)
+
+	src := config.Source{Src: "src", Format: config.FormatVOC}
+	reader, err := NewSourceReader(src.Format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.LoadDatasetConfig(fs, &src); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := reader.Items(fs, src, "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	want := "1 0.500000 0.500000 0.500000 0.500000\n0 0.250000 0.250000 0.500000 0.500000"
+	if got := string(items[0].Label.Data); got != want {
+		t.Errorf("label = %q, want %q", got, want)
+	}
+	if items[0].Image.SrcPath != "src/JPEGImages/a.jpg" || items[0].Cat != "valid" {
+		t.Errorf("unexpected item %+v in %s", items[0].Image, items[0].Cat)
+	}
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// yoloReader reads YOLO layout sources: data.yaml on root and
+// `<category>/images` with matching `<category>/labels/*.txt`.
+type yoloReader struct{}
+
+func (yoloReader) LoadDatasetConfig(fs afero.Fs, src *config.Source) error {
+	return src.LoadDatasetConfig(fs)
+}
+
+// Items lists items of splits declared on data.yaml. Splits are detected by folder name
+// when data.yaml declares none, or none of its declared paths is found.
+func (r yoloReader) Items(fs afero.Fs, src config.Source, dest string) ([]*DatasetItem, error) {
+	if src.DatasetConfig != nil {
+		if splits := src.DatasetConfig.Splits(); len(splits) > 0 {
+			if items, found := r.splitItems(fs, src, splits, dest); found {
+				return items, nil
+			}
+			log.Warn().Str("src", src.Src).Msg("No split path of data.yaml found, detect splits by folder name")
+		}
+	}
+	return r.folderItems(fs, src, dest)
+}
+
+// folderItems lists items of category folders found on source root, detected by folder name
+func (yoloReader) folderItems(fs afero.Fs, src config.Source, dest string) ([]*DatasetItem, error) {
+	entry, err := afero.ReadDir(fs, src.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*DatasetItem
+
+	// Lookup for each folder on root source directory
+	// Ensures the datasets folder on cross-named folder category
+	for _, e := range entry {
+		if !e.IsDir() {
+			continue
+		}
+
+		dir := path.Join(src.Src, e.Name())
+
+		cat := utils.FindCategory(e.Name())
+		if cat == nil {
+			continue
+		}
+
+		// read image filename as key
+		images, err := afero.ReadDir(fs, path.Join(dir, "images"))
+		if err != nil {
+			log.Warn().Err(err).Str("dir", dir).Msg("Failed collect from folder")
+			continue
+		}
+
+		log.Info().Any("name", e.Name()).Str("Path", dir).Msg("Collecting dataset on folder.")
+		for _, f := range images {
+			items = append(items, CreateDatasetItem(dir, dest, f.Name(), *cat))
+		}
+	}
+
+	return items, nil
+}
+
+// splitOrder is the order splits declared on data.yaml are listed in
+var splitOrder = []utils.Category{utils.CategoryTrain, utils.CategoryValid, utils.CategoryTest}
+
+// splitItems lists images of every split path declared on data.yaml, paths being relative to
+// dataset path or source root. Returns whether any declared path was found.
+func (yoloReader) splitItems(fs afero.Fs, src config.Source, splits map[utils.Category]config.SplitPaths, dest string) ([]*DatasetItem, bool) {
+	root := src.Src
+	if p := src.DatasetConfig.Path; p != "" {
+		if !path.IsAbs(p) {
+			p = path.Join(src.Src, p)
+		}
+		// Dataset path often points to the machine dataset was exported on
+		if ok, _ := afero.DirExists(fs, p); ok {
+			root = p
+		}
+	}
+
+	var (
+		items []*DatasetItem
+		found bool
+	)
+	for _, cat := range splitOrder {
+		seen := make(map[string]bool)
+		for _, p := range splits[cat] {
+			images, err := splitImages(fs, root, p)
+			if err != nil {
+				log.Warn().Err(err).Str("split", string(cat)).Msg("Failed read split path")
+				continue
+			}
+			found = true
+
+			log.Info().Any("name", cat).Str("Path", p).Msg("Collecting dataset on split path.")
+			for _, img := range images {
+				if seen[img] {
+					continue
+				}
+				seen[img] = true
+
+				item := CreateDatasetItem(path.Dir(img), dest, path.Base(img), cat)
+				item.Image.SrcPath = img
+				item.Label.SrcPath = yoloLabelPath(img)
+				items = append(items, item)
+			}
+		}
+	}
+	return items, found
+}
+
+// resolveSplitPath resolves split path relative to root. Like Ultralytics, a missing
+// "../" path is looked up on root itself, as exported by Roboflow.
+func resolveSplitPath(fs afero.Fs, root, p string) (string, error) {
+	candidates := []string{p}
+	if !path.IsAbs(p) {
+		candidates = []string{path.Join(root, p)}
+		if strings.HasPrefix(p, "../") {
+			candidates = append(candidates, path.Join(root, strings.TrimPrefix(p, "../")))
+		}
+	}
+
+	for _, c := range candidates {
+		if ok, _ := afero.Exists(fs, c); ok {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("split path %q not found: %w", p, os.ErrNotExist)
+}
+
+// splitImages returns images of split path, either every image found under directory
+// or every image listed by .txt file
+func splitImages(fs afero.Fs, root, p string) ([]string, error) {
+	resolved, err := resolveSplitPath(fs, root, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(path.Ext(resolved), ".txt") {
+		return imageList(fs, resolved)
+	}
+
+	var images []string
+	err = afero.Walk(fs, resolved, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if _, ok := imageFormats[strings.ToLower(path.Ext(name))]; ok {
+			images = append(images, name)
+		}
+		return nil
+	})
+	return images, err
+}
+
+// imageList reads image paths listed one per line, relative paths being relative to list directory
+func imageList(fs afero.Fs, list string) ([]string, error) {
+	data, err := afero.ReadFile(fs, list)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	for _, line := range strings.Split(string(data), "\n") {
+		img := strings.TrimSpace(line)
+		if img == "" {
+			continue
+		}
+		if !path.IsAbs(img) {
+			img = path.Join(path.Dir(list), img)
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// yoloLabelPath returns label path of image, replacing its last images folder by labels
+// as Ultralytics does. Label is placed beside image when not under an images folder.
+func yoloLabelPath(image string) string {
+	var (
+		dir, file = path.Split(image)
+		parts     = strings.Split(dir, "/")
+	)
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] == "images" {
+			parts[i] = "labels"
+			break
+		}
+	}
+	return strings.Join(parts, "/") + utils.RealFilename(utils.Filename(file), ".txt")
+}
+
+// YOLOBox is a label object with normalized center, width and height
+type YOLOBox struct {
+	Class int
+	X     float64
+	Y     float64
+	W     float64
+	H     float64
+}
+
+// ParseYOLOLabels parses every non-empty label line as YOLO box
+func ParseYOLOLabels(data []byte) ([]YOLOBox, error) {
+	var boxes []YOLOBox
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("line %d: expected 5 values, got %d", i+1, len(fields))
+		}
+
+		var (
+			box YOLOBox
+			err error
+			v   [4]float64
+		)
+		if box.Class, err = strconv.Atoi(fields[0]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		for j := range v {
+			if v[j], err = strconv.ParseFloat(fields[j+1], 64); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+		}
+		box.X, box.Y, box.W, box.H = v[0], v[1], v[2], v[3]
+		boxes = append(boxes, box)
+	}
+	return boxes, nil
+}
+
+// yoloWriter writes YOLO layout destination: data.yaml on root and
+// `<category>/images` with matching `<category>/labels/*.txt`.
+type yoloWriter struct{}
+
+func (yoloWriter) Load(fs afero.Fs, dest string) (*config.Dataset, error) {
+	src := path.Join(dest, "data.yaml")
+	if ok, _ := afero.Exists(fs, src); !ok {
+		return nil, nil
+	}
+	return config.LoadDataset(fs, src)
+}
+
+// CreateDestFolder creates the destination directory structure for training, testing, and validation datasets
+// with separate subdirectories for images and labels. Returns an error if directory creation fails.
+func (yoloWriter) CreateDestFolder(fs afero.Fs, dest string) error {
+	var err error
+
+	// Create dest root directory
+	err = fs.MkdirAll(dest, os.ModePerm)
+
+	// Create training dataset directory on root directory
+	err = fs.MkdirAll(path.Join(dest, "train", "images"), os.ModePerm)
+	err = fs.MkdirAll(path.Join(dest, "train", "labels"), os.ModePerm)
+
+	// Create testing dataset directory on root directory
+	err = fs.MkdirAll(path.Join(dest, "test", "images"), os.ModePerm)
+	err = fs.MkdirAll(path.Join(dest, "test", "labels"), os.ModePerm)
+
+	// Create validation dataset directory on root directory
+	err = fs.MkdirAll(path.Join(dest, "valid", "images"), os.ModePerm)
+	err = fs.MkdirAll(path.Join(dest, "valid", "labels"), os.ModePerm)
+
+	return err
+}
+
+func (yoloWriter) CreateConfig(fs afero.Fs, dataset config.Dataset, dest string) error {
+	return config.SaveDataset(fs, dataset, dest)
+}
+
+// SetDestPaths keeps paths set by DatasetItem.SetNewFilename, which follows YOLO layout.
+func (yoloWriter) SetDestPaths(item *DatasetItem) {}
+
+func (yoloWriter) Write(fs afero.Fs, item *DatasetItem) (err error) {
+	// Delete file on error
+	defer func() {
+		if err != nil {
+			fs.Remove(item.Image.DstPath)
+			fs.Remove(item.Label.DstPath)
+		}
+	}()
+
+	if err = writeImage(fs, item.Image); err != nil {
+		return err
+	}
+	if err = writeFile(fs, item.Label.DstPath, item.Label.Data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (yoloWriter) Index(item *DatasetItem) error { return nil }
+
+func (yoloWriter) Close(fs afero.Fs) error { return nil }
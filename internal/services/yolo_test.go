@@ -0,0 +1,92 @@
+package services
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/evilmagics/dataset_collector/internal/config"
+	"github.com/evilmagics/dataset_collector/internal/utils"
+	"github.com/spf13/afero"
+)
+
+func TestYOLOSplitPaths(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, f := range []string{
+		"src/images/train/a.png", "src/images/train/b.png",
+		"src/extra/images/c.png",
+		"src/images/val/d.png",
+		"/abs/images/e.png",
+		"src/lists/f.png",
+		// Folder named as category, not declared on data.yaml
+		"src/test/images/g.png",
+	} {
+		afero.WriteFile(fs, f, testImage(t, 20, 10), 0644)
+	}
+	afero.WriteFile(fs, "src/lists/test.txt", []byte("./f.png\n/abs/images/e.png\n\n"), 0644)
+	afero.WriteFile(fs, "src/data.yaml", []byte(`path: /content/datasets/src
+train: [images/train, extra/images]
+val: ../images/val
+valid: ignored
+test: lists/test.txt
+names: ["bus", "car"]
+`), 0644)
+
+	src := config.Source{Src: "src"}
+	reader := yoloReader{}
+	if err := reader.LoadDatasetConfig(fs, &src); err != nil {
+		t.Fatal(err)
+	}
+	items, err := reader.Items(fs, src, "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]utils.Category)
+	for _, item := range items {
+		got[item.Image.SrcPath] = item.Cat
+	}
+	want := map[string]utils.Category{
+		"src/images/train/a.png": utils.CategoryTrain,
+		"src/images/train/b.png": utils.CategoryTrain,
+		"src/extra/images/c.png": utils.CategoryTrain,
+		"src/images/val/d.png":   utils.CategoryValid,
+		"src/lists/f.png":        utils.CategoryTest,
+		"/abs/images/e.png":      utils.CategoryTest,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d items, want %d: %v", len(got), len(want), got)
+	}
+	for img, cat := range want {
+		if got[img] != cat {
+			t.Errorf("%s got category %q, want %q", img, got[img], cat)
+		}
+	}
+
+	labels := make([]string, 0, len(items))
+	for _, item := range items {
+		labels = append(labels, item.Label.SrcPath)
+	}
+	sort.Strings(labels)
+	wantLabels := []string{
+		"/abs/labels/e.txt",
+		"src/extra/labels/c.txt",
+		"src/labels/train/a.txt",
+		"src/labels/train/b.txt",
+		"src/labels/val/d.txt",
+		"src/lists/f.txt",
+	}
+	for i := range wantLabels {
+		if i >= len(labels) || labels[i] != wantLabels[i] {
+			t.Fatalf("got labels %v, want %v", labels, wantLabels)
+		}
+	}
+
+	// Splits are detected by folder name when no declared path is found
+	afero.WriteFile(fs, "src/data.yaml", []byte("train: missing/images\nnames: [\"bus\", \"car\"]\n"), 0644)
+	if err := reader.LoadDatasetConfig(fs, &src); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ = reader.Items(fs, src, "dst"); len(items) != 1 || items[0].Image.SrcPath != "src/test/images/g.png" {
+		t.Errorf("got %d items, want g.png detected by folder name", len(items))
+	}
+}
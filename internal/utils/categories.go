@@ -16,6 +16,7 @@ var (
 		"testings":   CategoryTest,
 		"train":      CategoryTrain,
 		"training":   CategoryTrain,
+		"val":        CategoryValid,
 		"valid":      CategoryValid,
 		"validation": CategoryValid,
 	}
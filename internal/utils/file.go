@@ -32,3 +32,49 @@ func ImagePath(dir, filename string, cat ...Category) string {
 	}
 	return path.Join(dir, "images", filename)
 }
+
+// NaturalLess compares strings with digit runs compared by numeric value,
+// so "train_2.jpg" is less than "train_10.jpg"
+func NaturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitsPrefix(a), digitsPrefix(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digitsPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// archiveExts lists extensions of supported archive files
+var archiveExts = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// IsArchive reports whether src is a supported archive file by its extension
+func IsArchive(src string) bool {
+	src = strings.ToLower(src)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(src, ext) {
+			return true
+		}
+	}
+	return false
+}
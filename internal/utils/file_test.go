@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestNaturalLess(t *testing.T) {
+	names := []string{"train_10.jpg", "train_2.jpg", "test_1.jpg", "train_1.jpg", "train_02.txt"}
+	sort.Slice(names, func(i, j int) bool { return NaturalLess(names[i], names[j]) })
+
+	want := []string{"test_1.jpg", "train_1.jpg", "train_2.jpg", "train_02.txt", "train_10.jpg"}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got %v, want %v", names, want)
+		}
+	}
+}
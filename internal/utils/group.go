@@ -0,0 +1,33 @@
+package utils
+
+import "regexp"
+
+// RoboflowGroupPattern matches augmented variants exported by Roboflow,
+// e.g. "IMG_0276_jpg.rf.379870ded6225363f38a26eeaa27714f.jpg" grouped by "IMG_0276_jpg"
+const RoboflowGroupPattern = `^(.+?_(?:jpe?g|png|bmp|webp|tiff?))\.rf\.[0-9a-fA-F]+`
+
+// Grouper extracts group key from filename, grouping files derived from the same original
+type Grouper struct {
+	re *regexp.Regexp
+}
+
+// NewGrouper compiles pattern, where first submatch (or whole match without any) is the group key
+func NewGrouper(pattern string) (*Grouper, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Grouper{re: re}, nil
+}
+
+// Key returns group key of filename, or filename itself when not matched
+func (g Grouper) Key(filename string) string {
+	m := g.re.FindStringSubmatch(filename)
+	switch {
+	case m == nil:
+		return filename
+	case len(m) > 1:
+		return m[1]
+	}
+	return m[0]
+}
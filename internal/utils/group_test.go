@@ -0,0 +1,21 @@
+package utils
+
+import "testing"
+
+func TestGrouperRoboflow(t *testing.T) {
+	g, err := NewGrouper(RoboflowGroupPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for filename, want := range map[string]string{
+		"IMG_0276_jpg.rf.379870ded6225363f38a26eeaa27714f.jpg": "IMG_0276_jpg",
+		"IMG_0276_jpg.rf.9aea5c327651a32b46d8dbca3555f765.jpg": "IMG_0276_jpg",
+		"frame_01_png.rf.abf631c2d250c5fb92e9ac96d79fa398.jpg": "frame_01_png",
+		"IMG_0300.jpg": "IMG_0300.jpg",
+	} {
+		if got := g.Key(filename); got != want {
+			t.Errorf("Key(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"image"
+	"math/bits"
+)
+
+// DHash returns 64 bits difference hash of image, comparing brightness of
+// horizontally adjacent cells on 9x8 grayscale grid. Similar images give
+// hashes with small hamming distance.
+func DHash(img image.Image) uint64 {
+	const (
+		cols    = 9
+		rows    = 8
+		samples = 4 // samples per cell side
+	)
+
+	var (
+		b    = img.Bounds()
+		grid [rows][cols]float64
+	)
+	for cy := 0; cy < rows; cy++ {
+		for cx := 0; cx < cols; cx++ {
+			var sum float64
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					x := b.Min.X + (cx*samples+sx)*b.Dx()/(cols*samples)
+					y := b.Min.Y + (cy*samples+sy)*b.Dy()/(rows*samples)
+					r, g, bl, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+				}
+			}
+			grid[cy][cx] = sum
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols-1; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns count of different bits between hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
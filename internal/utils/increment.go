@@ -15,9 +15,22 @@ func (i *Increment) Increase() int {
 	return i.counter
 }
 
-func NewIncrement() *Increment {
-	return &Increment{
+// Current returns the last increased value
+func (i *Increment) Current() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.counter
+}
+
+// NewIncrement creates counter starting after optional start value
+func NewIncrement(start ...int) *Increment {
+	incr := &Increment{
 		mu:      new(sync.Mutex),
 		counter: 0,
 	}
+	if len(start) > 0 {
+		incr.counter = start[0]
+	}
+	return incr
 }
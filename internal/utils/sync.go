@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/json"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 )
@@ -24,6 +25,21 @@ func (s *ClassNameSync) UnmarshalYAML(node *yaml.Node) error {
 		return err
 	}
 
+	s.setRaw(raw)
+	return nil
+}
+
+func (s *ClassNameSync) UnmarshalJSON(b []byte) error {
+	var raw map[string][]string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	s.setRaw(raw)
+	return nil
+}
+
+func (s *ClassNameSync) setRaw(raw map[string][]string) {
 	// save raw classes name synchronizing
 	s.raw = raw
 
@@ -35,8 +51,16 @@ func (s *ClassNameSync) UnmarshalYAML(node *yaml.Node) error {
 			s.index[i] = k
 		}
 	}
+}
 
-	return nil
+// Targets returns sorted destination class names of synchronizing
+func (s ClassNameSync) Targets() []string {
+	targets := make([]string, 0, len(s.raw))
+	for k := range s.raw {
+		targets = append(targets, k)
+	}
+	sort.Strings(targets)
+	return targets
 }
 
 func (s ClassNameSync) GetCrossName(class string) *string {